@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/shanth1/gotools/conf"
@@ -9,28 +11,50 @@ import (
 	"github.com/shanth1/gotools/ctx"
 	"github.com/shanth1/gotools/flags"
 	"github.com/shanth1/gotools/log"
-	"github.com/shanth1/hookrelay/internal/app"
-	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/pkg/hookrelay"
 )
 
 type Flags struct {
 	ConfigPath string `flag:"config" usage:"Path to the YAML config file"`
 }
 
-func main() {
-	ctx, shutdownCtx, cancel, shutdownCancel := ctx.WithGracefulShutdown(10 * time.Second)
-	defer cancel()
-	defer shutdownCancel()
+const validateCommand = "validate"
 
+func main() {
 	logger := log.New()
 
 	flagCfg := &Flags{}
 	if err := flags.RegisterFromStruct(flagCfg); err != nil {
 		logger.Fatal().Err(err).Msg("register flags")
 	}
-	flag.Parse()
+	flag.Usage = usage
+
+	args, command := os.Args[1:], ""
+	if len(args) > 0 && args[0] == validateCommand {
+		args, command = args[1:], validateCommand
+	}
+	flag.CommandLine.Parse(args) // exits on invalid flags
+
+	if command == validateCommand {
+		os.Exit(validate(flagCfg.ConfigPath))
+	}
+	serve(logger, flagCfg)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [%s] [flags]\n\n", os.Args[0], validateCommand)
+	fmt.Fprintf(out, "Without a command the server runs, '%s' checks the config and exits non-zero if it has problems.\n\n", validateCommand)
+	fmt.Fprintln(out, "Flags:")
+	flag.PrintDefaults()
+}
 
-	cfg := &config.Config{}
+func serve(logger log.Logger, flagCfg *Flags) {
+	ctx, shutdownCtx, cancel, shutdownCancel := ctx.WithGracefulShutdown(10 * time.Second)
+	defer cancel()
+	defer shutdownCancel()
+
+	cfg := &hookrelay.Config{}
 	if err := conf.Load(flagCfg.ConfigPath, cfg); err != nil {
 		logger.Fatal().Err(err).Msg("load config")
 	}
@@ -46,5 +70,16 @@ func main() {
 	}))
 
 	ctx = log.NewContext(ctx, logger)
-	app.Run(ctx, shutdownCtx, cfg)
+	server, err := hookrelay.New(cfg, hookrelay.WithLogger(logger))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to initialize server")
+	}
+	go server.WatchConfig(ctx, flagCfg.ConfigPath, func() (*hookrelay.Config, error) {
+		cfg := &hookrelay.Config{}
+		return cfg, conf.Load(flagCfg.ConfigPath, cfg)
+	})
+	if err := server.Run(ctx, shutdownCtx); err != nil {
+		logger.Fatal().Err(err).Msg("server failed")
+	}
+	logger.Info().Msg("application shutdown complete")
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shanth1/gotools/conf"
+	"github.com/shanth1/hookrelay/pkg/hookrelay"
+)
+
+// validate reports the problems of the config at path and returns the exit code
+func validate(path string) int {
+	cfg := &hookrelay.Config{}
+	if err := conf.Load(path, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to load config: %v\n", path, err)
+		return 1
+	}
+
+	errs := hookrelay.Validate(cfg)
+	if len(errs) == 0 {
+		fmt.Printf("%s: config is valid\n", path)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", path, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  - %v\n", err)
+	}
+	return 1
+}
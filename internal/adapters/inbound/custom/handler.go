@@ -15,13 +15,24 @@ type Handler struct {
 
 var _ ports.WebhookHandler = (*Handler)(nil)
 
+// Validate checks the settings of a custom webhook
+func Validate(secret string) error {
+	if secret == "" {
+		// requests are verified by comparing a header with the secret, which must not be empty
+		return fmt.Errorf("empty 'secret' value")
+	}
+	return nil
+}
+
 func NewHandler(secret string) ports.WebhookHandler {
 	return &Handler{
 		secret: secret,
 	}
 }
 
-func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Notification, error) {
+const eventName = "custom"
+
+func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Event, error) {
 	if ok := h.verify(req); !ok {
 		return nil, common.ErrInvalidSignature
 	}
@@ -30,7 +41,21 @@ func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain
 		return nil, fmt.Errorf("request payload is empty")
 	}
 
-	return &domain.Notification{Body: string(req.Payload)}, nil
+	severity, err := domain.ParseSeverity(req.GetHeader("X-Severity"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid X-Severity header: %w", err)
+	}
+
+	return &domain.Event{Name: eventName, Payload: string(req.Payload), Severity: severity}, nil
+}
+
+func (h *Handler) Render(ctx context.Context, event domain.Event, opts domain.RenderOptions) (*domain.Notification, error) {
+	body, ok := event.Payload.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected custom event payload type %T", event.Payload)
+	}
+
+	return &domain.Notification{Body: body}, nil
 }
 
 func (h *Handler) verify(req ports.WebhookRequest) bool {
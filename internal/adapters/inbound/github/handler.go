@@ -1,10 +1,8 @@
 package github
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"text/template"
 
 	"github.com/shanth1/hookrelay/internal/common"
 	"github.com/shanth1/hookrelay/internal/core/domain"
@@ -12,26 +10,35 @@ import (
 )
 
 type Handler struct {
-	secret                  string
-	templates               *template.Template
-	disableUnknownTemplates bool
+	secret   string
+	renderer *common.TemplateRenderer
 }
 
 var _ ports.WebhookHandler = (*Handler)(nil)
 
+// Validate checks the settings of a github webhook and its templates, NewHandler expects them to be valid
+func Validate(secret string) error {
+	if secret == "" {
+		// github signs nothing without a secret, so every request would be rejected
+		return fmt.Errorf("empty 'secret' value")
+	}
+	_, err := newRenderer(false)
+	return err
+}
+
 func NewHandler(secret string, disableUnknownTemplates bool) (ports.WebhookHandler, error) {
-	tmpls, err := parseTemplates()
+	renderer, err := newRenderer(disableUnknownTemplates)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse github templates: %w", err)
+		return nil, err
 	}
+
 	return &Handler{
-		secret:                  secret,
-		templates:               tmpls,
-		disableUnknownTemplates: disableUnknownTemplates,
+		secret:   secret,
+		renderer: renderer,
 	}, nil
 }
 
-func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Notification, error) {
+func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Event, error) {
 	if ok := h.verify(req); !ok {
 		return nil, common.ErrInvalidSignature
 	}
@@ -41,21 +48,19 @@ func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain
 		return nil, fmt.Errorf("parse payload: %w", err)
 	}
 
-	templateName := common.GetTemplatePath(eventName)
-	if h.templates.Lookup(templateName) == nil {
-		templateExists := h.templates.Lookup(templateName) != nil
-		if !templateExists {
-			if h.disableUnknownTemplates {
-				return nil, nil
-			}
-			templateName = common.GetTemplatePath("default")
-		}
-	}
+	return &domain.Event{
+		Name:      eventName,
+		Payload:   payload,
+		EntityKey: entityKey(payload),
+		Severity:  severity(eventName, payload),
+	}, nil
+}
 
-	var message bytes.Buffer
-	if err := h.templates.ExecuteTemplate(&message, templateName, payload); err != nil {
-		return nil, fmt.Errorf("error executing github template '%s': %w", templateName, err)
+func (h *Handler) Render(ctx context.Context, event domain.Event, opts domain.RenderOptions) (*domain.Notification, error) {
+	notification, err := h.renderer.Render(event.Name, event.Payload, opts)
+	if err != nil {
+		return nil, fmt.Errorf("render github event: %w", err)
 	}
 
-	return &domain.Notification{Body: message.String()}, nil
+	return notification, nil
 }
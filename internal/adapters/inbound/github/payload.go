@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 
+	"github.com/shanth1/hookrelay/internal/core/domain"
 	"github.com/shanth1/hookrelay/internal/core/ports"
 )
 
@@ -34,3 +35,64 @@ func parsePayload(req ports.WebhookRequest) (payload map[string]interface{}, eve
 
 	return
 }
+
+// entityKey identifies the pull request or issue an event is about.
+// Pull requests and issues share numbers within a repository, so PR comments map to their PR.
+func entityKey(payload map[string]interface{}) string {
+	repository, _ := payload["repository"].(map[string]interface{})
+	repo, _ := repository["full_name"].(string)
+	if repo == "" {
+		return ""
+	}
+
+	for _, field := range []string{"pull_request", "issue"} {
+		entity, ok := payload[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if number, ok := entity["number"].(float64); ok {
+			return fmt.Sprintf("%s#%d", repo, int64(number))
+		}
+	}
+	return ""
+}
+
+var failedConclusions = map[string]bool{
+	"failure":         true,
+	"timed_out":       true,
+	"startup_failure": true,
+}
+
+// severity rates an event for recipient schedules. New security alerts are critical, as are failed checks
+// on the default branch and failed deployments. Failed checks on other branches are warnings.
+func severity(eventName string, payload map[string]interface{}) domain.Severity {
+	action, _ := payload["action"].(string)
+
+	switch eventName {
+	case "secret_scanning_alert", "code_scanning_alert", "dependabot_alert", "repository_vulnerability_alert":
+		if action == "created" || action == "create" || action == "reopened" {
+			return domain.SeverityCritical
+		}
+	case "workflow_run", "check_suite", "check_run":
+		run, _ := payload[eventName].(map[string]interface{})
+		conclusion, _ := run["conclusion"].(string)
+		if action != "completed" || !failedConclusions[conclusion] {
+			break
+		}
+		branch, _ := run["head_branch"].(string)
+		if suite, ok := run["check_suite"].(map[string]interface{}); ok && branch == "" {
+			branch, _ = suite["head_branch"].(string)
+		}
+		repository, _ := payload["repository"].(map[string]interface{})
+		if defaultBranch, _ := repository["default_branch"].(string); branch != "" && branch == defaultBranch {
+			return domain.SeverityCritical
+		}
+		return domain.SeverityWarning
+	case "deployment_status":
+		status, _ := payload["deployment_status"].(map[string]interface{})
+		if state, _ := status["state"].(string); state == "failure" || state == "error" {
+			return domain.SeverityCritical
+		}
+	}
+	return domain.SeverityInfo
+}
@@ -0,0 +1,20 @@
+package github
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.WebhookType = "github"
+
+func init() {
+	registry.RegisterWebhook(Type, registry.Webhook[struct{}]{
+		Validate: func(cfg config.WebhookConfig, _ struct{}) error {
+			return Validate(cfg.Secret)
+		},
+		New: func(cfg config.WebhookConfig, _ struct{}, env registry.Env) (ports.WebhookHandler, error) {
+			return NewHandler(cfg.Secret, env.Config.DisableUnknownTemplates)
+		},
+	})
+}
@@ -1,11 +1,10 @@
 package kanboard
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"text/template"
+	"net/url"
 
 	"github.com/shanth1/hookrelay/internal/common"
 	"github.com/shanth1/hookrelay/internal/core/domain"
@@ -19,37 +18,48 @@ type KanboardPayload struct {
 }
 
 type Handler struct {
-	secret                  string
-	baseURL                 string
-	templates               *template.Template
-	disableUnknownTemplates bool
+	secret   string
+	baseURL  string
+	renderer *common.TemplateRenderer
 }
 
 var _ ports.WebhookHandler = (*Handler)(nil)
 
-func NewHandler(secret, baseURL string, disableUnknownTemplates bool) (ports.WebhookHandler, error) {
+// Validate checks the settings of a kanboard webhook and its templates, NewHandler expects them to be valid
+func Validate(secret, baseURL string) error {
 	if secret == "" {
-		return nil, fmt.Errorf("empty 'secret' value")
+		return fmt.Errorf("empty 'secret' value")
 	}
 
-	// TODO: added url validation
 	if baseURL == "" {
-		return nil, fmt.Errorf("empty 'base_url' value")
+		return fmt.Errorf("empty 'base_url' value")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid 'base_url' value: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid 'base_url' value '%s': expected an absolute http(s) URL", baseURL)
 	}
 
-	tmpls, err := parseTemplates()
+	_, err = newRenderer(false)
+	return err
+}
+
+func NewHandler(secret, baseURL string, disableUnknownTemplates bool) (ports.WebhookHandler, error) {
+	renderer, err := newRenderer(disableUnknownTemplates)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse kanboard templates: %w", err)
+		return nil, err
 	}
+
 	return &Handler{
-		secret:                  secret,
-		baseURL:                 baseURL,
-		templates:               tmpls,
-		disableUnknownTemplates: disableUnknownTemplates,
+		secret:   secret,
+		baseURL:  baseURL,
+		renderer: renderer,
 	}, nil
 }
 
-func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Notification, error) {
+func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Event, error) {
 	if ok := h.verify(req); !ok {
 		return nil, common.ErrInvalidSignature
 	}
@@ -67,8 +77,14 @@ func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain
 		return nil, fmt.Errorf("kanboard event_name is missing from payload")
 	}
 
+	var entityKey string
+
 	// TODO: refactor
 	if taskData, ok := payload.EventData["task"].(map[string]interface{}); ok {
+		if taskID, ok := taskData["id"]; ok {
+			entityKey = fmt.Sprintf("task/%v", taskID)
+		}
+
 		taskIDFloat, tid_ok := taskData["id"].(float64)
 		projectIDFloat, pid_ok := taskData["project_id"].(float64)
 
@@ -83,21 +99,19 @@ func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain
 		}
 	}
 
-	templateName := common.GetTemplatePath(payload.EventName)
-	if h.templates.Lookup(templateName) == nil {
-		templateExists := h.templates.Lookup(templateName) != nil
-		if !templateExists {
-			if h.disableUnknownTemplates {
-				return nil, nil
-			}
-			templateName = common.GetTemplatePath("default")
-		}
-	}
+	return &domain.Event{
+		Name:      payload.EventName,
+		Payload:   payload,
+		EntityKey: entityKey,
+		Severity:  severity(payload),
+	}, nil
+}
 
-	var message bytes.Buffer
-	if err := h.templates.ExecuteTemplate(&message, templateName, payload); err != nil {
-		return nil, fmt.Errorf("error executing kanboard template '%s': %w", templateName, err)
+func (h *Handler) Render(ctx context.Context, event domain.Event, opts domain.RenderOptions) (*domain.Notification, error) {
+	notification, err := h.renderer.Render(event.Name, event.Payload, opts)
+	if err != nil {
+		return nil, fmt.Errorf("render kanboard event: %w", err)
 	}
 
-	return &domain.Notification{Body: message.String()}, nil
+	return notification, nil
 }
@@ -0,0 +1,46 @@
+package kanboard
+
+import (
+	"strconv"
+
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// defaultPriorityEnd is the highest task priority of a Kanboard project unless the project changes it
+const defaultPriorityEnd = 3
+
+// severity rates an event for recipient schedules. Tasks created with the highest priority of their project
+// are critical, overdue tasks are warnings.
+func severity(payload KanboardPayload) domain.Severity {
+	switch payload.EventName {
+	case "task.overdue":
+		return domain.SeverityWarning
+	case "task.create":
+		task, _ := payload.EventData["task"].(map[string]interface{})
+		priority, ok := toInt(task["priority"])
+		if !ok {
+			break
+		}
+		highest, ok := toInt(task["priority_end"])
+		if !ok {
+			highest = defaultPriorityEnd
+		}
+		if priority >= highest {
+			return domain.SeverityCritical
+		}
+	}
+	return domain.SeverityInfo
+}
+
+// toInt reads a number that Kanboard may send as a JSON number or a string
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
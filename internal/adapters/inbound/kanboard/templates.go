@@ -2,12 +2,38 @@ package kanboard
 
 import (
 	"embed"
+	"fmt"
 	"text/template"
+
+	"github.com/shanth1/hookrelay/internal/common"
 )
 
 //go:embed templates/*.tmpl
 var templateFiles embed.FS
 
+//go:embed templates/subjects/*.tmpl
+var subjectFiles embed.FS
+
 func parseTemplates() (*template.Template, error) {
-	return template.New("kanboard").ParseFS(templateFiles, "templates/*.tmpl")
+	return template.New("kanboard").Funcs(common.TemplateFuncs()).ParseFS(templateFiles, "templates/*.tmpl")
+}
+
+func parseSubjectTemplates() (*template.Template, error) {
+	return template.New("kanboard-subjects").Funcs(common.TemplateFuncs()).ParseFS(subjectFiles, "templates/subjects/*.tmpl")
+}
+
+func newRenderer(disableUnknownTemplates bool) (*common.TemplateRenderer, error) {
+	tmpls, err := parseTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kanboard templates: %w", err)
+	}
+	subjects, err := parseSubjectTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kanboard subject templates: %w", err)
+	}
+	renderer, err := common.NewTemplateRenderer(tmpls, subjects, disableUnknownTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare kanboard templates: %w", err)
+	}
+	return renderer, nil
 }
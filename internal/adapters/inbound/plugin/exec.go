@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+)
+
+const (
+	// waitDelay bounds the wait for the output pipes after the plugin was killed
+	waitDelay = 2 * time.Second
+	// stderrLimit is how much of the error output goes into the error
+	stderrLimit = 4096
+)
+
+// execTransport runs the plugin once per request: the request is written to stdin as JSON,
+// the response is read from stdout
+type execTransport struct {
+	newCmd func(ctx context.Context) *exec.Cmd
+}
+
+func (t *execTransport) call(ctx context.Context, req Request) (*Response, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var stdout bytes.Buffer
+	stderr := common.NewLimitedBuffer(stderrLimit)
+	cmd := t.newCmd(ctx)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stderr.String())
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w, stderr: %s", ctx.Err(), output)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("exited with code %d, stderr: %s", exitErr.ExitCode(), output)
+		}
+		return nil, fmt.Errorf("failed to run: %w", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	ModeExec = "exec"
+	ModeRPC  = "rpc"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Request is what the plugin receives for a webhook request
+type Request struct {
+	Webhook string            `json:"webhook"`
+	Secret  string            `json:"secret"` // the secret of the webhook, for verifying the request
+	Headers map[string]string `json:"headers"`
+	Params  map[string]string `json:"params"`
+	Body    string            `json:"body"`
+}
+
+// Response is what the plugin answers. An empty event name means there is nothing to deliver.
+type Response struct {
+	Verified      bool            `json:"verified"`
+	Event         string          `json:"event"`
+	Severity      string          `json:"severity"`
+	Entity        string          `json:"entity"`
+	Payload       json.RawMessage `json:"payload"` // forwarded to message bus notifiers
+	Notifications []Notification  `json:"notifications"`
+}
+
+// Notification is a rendering of the event. Recipients get the most specific one matching their
+// locale and template variant, empty values match any recipient.
+type Notification struct {
+	Locale    string          `json:"locale"`
+	Variant   string          `json:"variant"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	ParseMode string          `json:"parse_mode"`
+	Severity  string          `json:"severity"`
+	Buttons   []domain.Button `json:"buttons"`
+}
+
+// transport delivers a request to the plugin process
+type transport interface {
+	call(ctx context.Context, req Request) (*Response, error)
+}
+
+type Handler struct {
+	name      string
+	secret    string
+	timeout   time.Duration
+	transport transport
+}
+
+var (
+	_ ports.WebhookHandler = (*Handler)(nil)
+	_ ports.Runner         = (*Handler)(nil)
+)
+
+// Validate checks the plugin settings of a webhook without looking for the command
+func Validate(cfg Settings) error {
+	if cfg.Command == "" {
+		return fmt.Errorf("empty 'plugin.command' value")
+	}
+	switch cfg.Mode {
+	case "", ModeExec, ModeRPC:
+		return nil
+	default:
+		return fmt.Errorf("unknown plugin mode '%s'", cfg.Mode)
+	}
+}
+
+func NewHandler(name config.WebhookName, secret string, cfg Settings) (*Handler, error) {
+	command, err := exec.LookPath(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("plugin command '%s' not found: %w", cfg.Command, err)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	env := os.Environ()
+	for name, value := range cfg.Env {
+		env = append(env, strings.ToUpper(name)+"="+value)
+	}
+	newCmd := func(ctx context.Context) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, command, cfg.Args...)
+		cmd.Env = env
+		common.KillProcessGroup(cmd)
+		return cmd
+	}
+
+	h := &Handler{name: string(name), secret: secret, timeout: timeout}
+	if cfg.Mode == ModeRPC {
+		h.transport = newSupervisor(string(name), newCmd, cfg.RestartDelay)
+	} else {
+		h.transport = &execTransport{newCmd: newCmd}
+	}
+	return h, nil
+}
+
+// Run keeps an 'rpc' plugin running until ctx is cancelled
+func (h *Handler) Run(ctx context.Context) error {
+	if runner, ok := h.transport.(ports.Runner); ok {
+		return runner.Run(ctx)
+	}
+	return nil
+}
+
+func (h *Handler) Handle(ctx context.Context, req ports.WebhookRequest) (*domain.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	resp, err := h.transport.call(ctx, Request{
+		Webhook: h.name,
+		Secret:  h.secret,
+		Headers: req.Headers,
+		Params:  req.Params,
+		Body:    string(req.Payload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plugin failed: %w", err)
+	}
+
+	if !resp.Verified {
+		return nil, common.ErrInvalidSignature
+	}
+	if resp.Event == "" {
+		return nil, nil
+	}
+	severity, err := domain.ParseSeverity(resp.Severity)
+	if err != nil {
+		return nil, fmt.Errorf("plugin returned an invalid severity: %w", err)
+	}
+	for _, n := range resp.Notifications {
+		if _, err := domain.ParseSeverity(n.Severity); err != nil {
+			return nil, fmt.Errorf("plugin returned an invalid notification severity: %w", err)
+		}
+	}
+
+	return &domain.Event{
+		Name:      resp.Event,
+		Payload:   event{payload: resp.Payload, notifications: resp.Notifications},
+		Severity:  severity,
+		EntityKey: resp.Entity,
+	}, nil
+}
+
+func (h *Handler) Render(ctx context.Context, e domain.Event, opts domain.RenderOptions) (*domain.Notification, error) {
+	ev, ok := e.Payload.(event)
+	if !ok {
+		return nil, fmt.Errorf("unexpected plugin event payload type %T", e.Payload)
+	}
+
+	n := ev.notificationFor(opts)
+	if n == nil {
+		return nil, nil
+	}
+	// an empty severity leaves the one of the event
+	var severity domain.Severity
+	if n.Severity != "" {
+		severity, _ = domain.ParseSeverity(n.Severity)
+	}
+	return &domain.Notification{
+		Title:     n.Title,
+		Body:      n.Body,
+		ParseMode: n.ParseMode,
+		Severity:  severity,
+		Buttons:   n.Buttons,
+	}, nil
+}
+
+// event is the payload of the events of a plugin, the notifications are rendered already
+type event struct {
+	payload       json.RawMessage
+	notifications []Notification
+}
+
+// MarshalJSON forwards the payload the plugin returned to notifiers that publish the event
+func (e event) MarshalJSON() ([]byte, error) {
+	if len(e.payload) == 0 {
+		return []byte("null"), nil
+	}
+	return e.payload, nil
+}
+
+func (e event) notificationFor(opts domain.RenderOptions) *Notification {
+	locale := opts.Locale
+	if locale == "" {
+		locale = common.DefaultLocale
+	}
+
+	var best *Notification
+	bestScore := -1
+	for i, n := range e.notifications {
+		if (n.Locale != "" && n.Locale != locale) || (n.Variant != "" && n.Variant != opts.Variant) {
+			continue
+		}
+		score := 0
+		if n.Locale != "" {
+			score++
+		}
+		if n.Variant != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = &e.notifications[i], score
+		}
+	}
+	return best
+}
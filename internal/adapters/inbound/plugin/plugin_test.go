@@ -0,0 +1,302 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// helperEnv makes the test binary act as a plugin in the given mode instead of running the tests
+const helperEnv = "HOOKRELAY_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case ModeExec:
+		runExecPlugin()
+	case ModeRPC:
+		runRPCPlugin()
+	default:
+		os.Exit(m.Run())
+	}
+}
+
+// answer is how the helper plugin handles a request, depending on its body:
+// 'fail' makes it exit with an error, 'exit' makes it exit quietly, 'hang' is never answered,
+// 'slow' is answered after a while and anything else right away with the pid as the entity
+func answer(req Request) Response {
+	return Response{
+		Verified: req.Secret == req.Headers["x-secret"],
+		Event:    "push",
+		Severity: "warning",
+		Entity:   fmt.Sprint(os.Getpid()),
+		Payload:  json.RawMessage(`{"body":` + fmt.Sprintf("%q", req.Body) + `}`),
+		Notifications: []Notification{
+			{Title: "Push", Body: req.Body},
+			{Locale: "ru", Title: "Пуш", Body: req.Body, Severity: "critical"},
+		},
+	}
+}
+
+func runExecPlugin() {
+	var req Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch req.Body {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "cannot parse the body")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	json.NewEncoder(os.Stdout).Encode(answer(req))
+	os.Exit(0)
+}
+
+func runRPCPlugin() {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var call struct {
+			ID     int64   `json:"id"`
+			Method string  `json:"method"`
+			Params Request `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil || call.Method != methodHandle {
+			fmt.Fprintln(os.Stderr, "unexpected request:", scanner.Text())
+			os.Exit(2)
+		}
+		switch call.Params.Body {
+		case "exit":
+			os.Exit(0)
+		case "hang":
+			continue
+		}
+		go func() {
+			if call.Params.Body == "slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			encoder.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": call.ID, "result": answer(call.Params)})
+		}()
+	}
+	os.Exit(0)
+}
+
+func newTestHandler(t *testing.T, mode string) *Handler {
+	t.Helper()
+	h, err := NewHandler("sentry", "secret", Settings{
+		Command: os.Args[0],
+		// a race-enabled binary sleeps for a second before exiting unless told not to
+		Env:          map[string]string{strings.ToLower(helperEnv): mode, "gorace": "atexit_sleep_ms=0"},
+		Mode:         mode,
+		Timeout:      time.Second,
+		RestartDelay: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return h
+}
+
+func request(body string) ports.WebhookRequest {
+	return ports.WebhookRequest{
+		Payload: []byte(body),
+		Headers: map[string]string{"x-secret": "secret"},
+		Params:  map[string]string{},
+	}
+}
+
+func TestHandle(t *testing.T) {
+	for _, mode := range []string{ModeExec, ModeRPC} {
+		t.Run(mode, func(t *testing.T) {
+			h := newTestHandler(t, mode)
+
+			e, err := h.Handle(context.Background(), request("main updated"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Name != "push" || e.Severity != domain.SeverityWarning || e.EntityKey == "" {
+				t.Errorf("event = %+v", e)
+			}
+			payload, _ := json.Marshal(e.Payload)
+			if string(payload) != `{"body":"main updated"}` {
+				t.Errorf("payload = %s", payload)
+			}
+
+			n, err := h.Render(context.Background(), *e, domain.RenderOptions{Locale: "ru"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.Title != "Пуш" || n.Severity != domain.SeverityCritical {
+				t.Errorf("notification = %+v, want the russian one", n)
+			}
+
+			bad := request("main updated")
+			bad.Headers["x-secret"] = "wrong"
+			if _, err := h.Handle(context.Background(), bad); !errors.Is(err, common.ErrInvalidSignature) {
+				t.Errorf("Handle() with a wrong secret = %v, want an invalid signature", err)
+			}
+		})
+	}
+}
+
+func TestExecFailures(t *testing.T) {
+	h := newTestHandler(t, ModeExec)
+
+	_, err := h.Handle(context.Background(), request("fail"))
+	if err == nil || !strings.Contains(err.Error(), "exited with code 3, stderr: cannot parse the body") {
+		t.Errorf("Handle() = %v, want the exit code and stderr", err)
+	}
+
+	_, err = h.Handle(context.Background(), request("hang"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Handle() = %v, want a timeout", err)
+	}
+}
+
+func TestRPCAnswersOutOfOrder(t *testing.T) {
+	h := newTestHandler(t, ModeRPC)
+
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	for i, body := range []string{"slow", "fast"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := h.Handle(context.Background(), request(body))
+			if err != nil {
+				t.Errorf("Handle(%s) = %v", body, err)
+				return
+			}
+			payload, _ := json.Marshal(e.Payload)
+			results[i] = string(payload)
+		}()
+	}
+	wg.Wait()
+
+	if results[0] != `{"body":"slow"}` || results[1] != `{"body":"fast"}` {
+		t.Errorf("results = %v, want each request its own answer", results)
+	}
+}
+
+func TestRPCRestarts(t *testing.T) {
+	h := newTestHandler(t, ModeRPC)
+	pid := func() string {
+		t.Helper()
+		e, err := h.Handle(context.Background(), request("ping"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e.EntityKey
+	}
+
+	first := pid()
+	if again := pid(); again != first {
+		t.Fatalf("pid %s changed to %s without a restart", first, again)
+	}
+
+	_, err := h.Handle(context.Background(), request("hang"))
+	if err == nil || !strings.Contains(err.Error(), "no response in time, restarting the plugin") {
+		t.Fatalf("Handle() = %v, want a timeout", err)
+	}
+	second := pid()
+	if second == first {
+		t.Errorf("hung plugin %s was not restarted", first)
+	}
+
+	if _, err := h.Handle(context.Background(), request("exit")); err == nil {
+		t.Fatal("Handle() answered by an exiting plugin succeeded")
+	}
+	if third := pid(); third == second {
+		t.Errorf("exited plugin %s was not restarted", second)
+	}
+}
+
+func TestRPCTimeoutKeepsAnsweringPlugin(t *testing.T) {
+	h := newTestHandler(t, ModeRPC)
+	first, err := h.Handle(context.Background(), request("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	hung := make(chan error, 1)
+	go func() {
+		_, err := h.Handle(context.Background(), request("hang"))
+		hung <- err
+	}()
+
+	// answered while the hung request waits, so the plugin is alive
+	time.Sleep(100 * time.Millisecond)
+	if _, err := h.Handle(context.Background(), request("ping")); err != nil {
+		t.Fatal(err)
+	}
+	// still in flight when the hung request times out
+	time.Sleep(800*time.Millisecond - time.Since(start))
+	slow := make(chan error, 1)
+	go func() {
+		_, err := h.Handle(context.Background(), request("slow"))
+		slow <- err
+	}()
+
+	err = <-hung
+	if err == nil || !strings.Contains(err.Error(), "no response in time") || strings.Contains(err.Error(), "restarting") {
+		t.Errorf("Handle() = %v, want a timeout without a restart", err)
+	}
+	if err := <-slow; err != nil {
+		t.Errorf("Handle() in flight during the timeout = %v", err)
+	}
+	e, err := h.Handle(context.Background(), request("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.EntityKey != first.EntityKey {
+		t.Errorf("plugin %s was restarted as %s", first.EntityKey, e.EntityKey)
+	}
+}
+
+func TestRPCHungPluginFailsRequestsInFlight(t *testing.T) {
+	h := newTestHandler(t, ModeRPC)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := h.Handle(context.Background(), request("hang"))
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Error("Handle() to a hung plugin succeeded")
+		}
+	}
+	if _, err := h.Handle(context.Background(), request("ping")); err != nil {
+		t.Errorf("Handle() after the restart = %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.WebhookType = "plugin"
+
+// Settings runs an external program that verifies and renders the requests of a webhook
+type Settings struct {
+	Command      string            `mapstructure:"command"`       // executable, looked up in PATH unless it contains a slash
+	Args         []string          `mapstructure:"args"`          // passed to the command as is
+	Env          map[string]string `mapstructure:"env"`           // added to the environment of hookrelay, names are upper-cased
+	Mode         string            `mapstructure:"mode"`          // 'exec' (default) runs the plugin per request, 'rpc' keeps it running
+	Timeout      time.Duration     `mapstructure:"timeout"`       // for handling one request, '10s' by default; an 'rpc' plugin answering nothing in time is restarted
+	RestartDelay time.Duration     `mapstructure:"restart_delay"` // before restarting an 'rpc' plugin, doubled up to a minute while it keeps failing, '1s' by default
+}
+
+func init() {
+	registry.RegisterWebhook(Type, registry.Webhook[Settings]{
+		Validate: func(_ config.WebhookConfig, settings Settings) error {
+			return Validate(settings)
+		},
+		New: func(cfg config.WebhookConfig, settings Settings, _ registry.Env) (ports.WebhookHandler, error) {
+			return NewHandler(cfg.Name, cfg.Secret, settings)
+		},
+	})
+}
@@ -0,0 +1,335 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+)
+
+const (
+	methodHandle = "handle"
+
+	defaultRestartDelay = time.Second
+	maxRestartDelay     = time.Minute
+	// stableRun is how long a plugin has to run for the restart delay to start over
+	stableRun = time.Minute
+)
+
+// supervisor keeps an 'rpc' plugin running and restarts it with a growing delay after it exits.
+// A plugin that does not answer a request in time, and nothing else since the request was sent,
+// is considered hung and restarted as well.
+type supervisor struct {
+	name         string
+	newCmd       func(ctx context.Context) *exec.Cmd
+	restartDelay time.Duration
+
+	mu      sync.Mutex
+	current *session
+	ready   chan struct{} // closed while a session is running
+}
+
+func newSupervisor(name string, newCmd func(ctx context.Context) *exec.Cmd, restartDelay time.Duration) *supervisor {
+	if restartDelay <= 0 {
+		restartDelay = defaultRestartDelay
+	}
+	return &supervisor{
+		name:         name,
+		newCmd:       newCmd,
+		restartDelay: restartDelay,
+		ready:        make(chan struct{}),
+	}
+}
+
+func (s *supervisor) Run(ctx context.Context) error {
+	logger := log.FromContext(ctx).With(log.Str("webhook", s.name))
+	delay := s.restartDelay
+
+	for {
+		started := time.Now()
+		sess, err := startSession(ctx, s.newCmd, logger)
+		if err == nil {
+			logger.Info().Msg("plugin started")
+			s.setSession(sess)
+			<-sess.done
+			s.dropSession(sess)
+			err = sess.err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if time.Since(started) >= stableRun {
+			delay = s.restartDelay
+		}
+		logger.Warn().Err(err).Str("restart_in", delay.String()).Msg("plugin stopped, restarting")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+func (s *supervisor) setSession(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = sess
+	close(s.ready)
+}
+
+// dropSession makes calls wait for the next session, unless sess was replaced already
+func (s *supervisor) dropSession(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == sess {
+		s.current = nil
+		s.ready = make(chan struct{})
+	}
+}
+
+// call waits for the plugin to be running, e.g. while it restarts, as long as ctx allows
+func (s *supervisor) call(ctx context.Context, req Request) (*Response, error) {
+	var sess *session
+	for sess == nil {
+		s.mu.Lock()
+		current, ready := s.current, s.ready
+		s.mu.Unlock()
+
+		if current != nil {
+			select {
+			case <-current.done:
+				s.dropSession(current)
+			default:
+				sess = current
+			}
+			continue
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("plugin is not running: %w", ctx.Err())
+		}
+	}
+
+	var resp Response
+	sent := time.Now()
+	err := sess.call(ctx, methodHandle, req, &resp)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// other requests in flight keep going as long as the plugin answers some
+		if sess.answeredSince(sent) {
+			return nil, fmt.Errorf("no response in time: %w", err)
+		}
+		s.dropSession(sess)
+		sess.kill()
+		return nil, fmt.Errorf("no response in time, restarting the plugin: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// session is a running plugin process that takes JSON-RPC 2.0 requests, one JSON object per line,
+// on stdin and answers them in any order on stdout. Lines on stderr are logged.
+type session struct {
+	cmd    *exec.Cmd
+	writes chan []byte
+
+	mu         sync.Mutex
+	nextID     int64
+	pending    map[int64]chan rpcResponse
+	lastAnswer atomic.Int64 // unix nanoseconds of the latest response
+
+	done chan struct{} // closed when the process has exited
+	err  error         // why the process exited, set before done is closed
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+func startSession(ctx context.Context, newCmd func(ctx context.Context) *exec.Cmd, logger log.Logger) (*session, error) {
+	cmd := newCmd(ctx)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start: %w", err)
+	}
+
+	sess := &session{
+		cmd:     cmd,
+		writes:  make(chan []byte),
+		pending: make(map[int64]chan rpcResponse),
+		done:    make(chan struct{}),
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	var readErr error
+	go func() {
+		defer readers.Done()
+		if readErr = sess.readResponses(stdout, logger); readErr != nil {
+			// the plugin cannot be understood any more
+			sess.kill()
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Warn().Str("output", scanner.Text()).Msg("plugin stderr")
+		}
+	}()
+	go sess.writeRequests(stdin)
+
+	go func() {
+		// Wait closes the pipes, so it has to come after the readers are finished
+		readers.Wait()
+		err := cmd.Wait()
+		if readErr != nil {
+			err = readErr
+		}
+		if err == nil {
+			err = errors.New("plugin exited")
+		}
+		sess.err = err
+		close(sess.done)
+	}()
+
+	return sess, nil
+}
+
+func (s *session) writeRequests(stdin io.WriteCloser) {
+	defer stdin.Close()
+	for {
+		select {
+		case line := <-s.writes:
+			if _, err := stdin.Write(line); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *session) readResponses(stdout io.Reader, logger log.Logger) error {
+	decoder := json.NewDecoder(stdout)
+	for {
+		var resp rpcResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		s.lastAnswer.Store(time.Now().UnixNano())
+
+		s.mu.Lock()
+		ch, ok := s.pending[resp.ID]
+		delete(s.pending, resp.ID)
+		s.mu.Unlock()
+		if !ok {
+			logger.Warn().Any("id", resp.ID).Msg("plugin answered an unknown request")
+			continue
+		}
+		ch <- resp
+	}
+}
+
+func (s *session) call(ctx context.Context, method string, params, result interface{}) error {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	ch := make(chan rpcResponse, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	line, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	select {
+	case s.writes <- append(line, '\n'):
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return s.err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("invalid result: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return s.err
+	}
+}
+
+// answeredSince reports whether the plugin answered any request after t
+func (s *session) answeredSince(t time.Time) bool {
+	return s.lastAnswer.Load() > t.UnixNano()
+}
+
+func (s *session) kill() {
+	if s.cmd.Cancel != nil {
+		s.cmd.Cancel()
+	}
+}
@@ -0,0 +1,230 @@
+package amqpbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	ConfirmAck  = "ack"
+	ConfirmNone = "none"
+
+	defaultTimeout       = 10 * time.Second
+	defaultMaxReconnects = 3
+	defaultReconnectWait = 2 * time.Second
+	closeTimeout         = 2 * time.Second
+)
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	url           string
+	exchange      string
+	routingKey    string
+	confirm       bool
+	deliveryMode  uint8
+	maxReconnects int
+	reconnectWait time.Duration
+	timeout       time.Duration
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel // kept open between messages, reopened after the broker closed it
+}
+
+// Validate checks the settings without connecting to the broker
+func Validate(cfg Settings) error {
+	if cfg.URL == "" {
+		return fmt.Errorf("empty 'url' value")
+	}
+	if _, err := amqp.ParseURI(cfg.URL); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	switch cfg.Confirm {
+	case "", ConfirmAck, ConfirmNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown confirm mode '%s'", cfg.Confirm)
+	}
+}
+
+// NewSender expects settings that passed Validate, it connects on the first message
+func NewSender(cfg Settings) *Sender {
+	deliveryMode := amqp.Persistent
+	if cfg.Transient {
+		deliveryMode = amqp.Transient
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxReconnects := cfg.MaxReconnects
+	if maxReconnects <= 0 {
+		maxReconnects = defaultMaxReconnects
+	}
+	reconnectWait := cfg.ReconnectWait
+	if reconnectWait <= 0 {
+		reconnectWait = defaultReconnectWait
+	}
+
+	return &Sender{
+		url:           cfg.URL,
+		exchange:      cfg.Exchange,
+		routingKey:    cfg.RoutingKey,
+		confirm:       cfg.Confirm != ConfirmNone,
+		deliveryMode:  deliveryMode,
+		maxReconnects: maxReconnects,
+		reconnectWait: reconnectWait,
+		timeout:       timeout,
+	}
+}
+
+// Send publishes the message to the exchange with the routing key in recipient.Target, or in the settings.
+// A message published on a connection the broker has dropped is published again after reconnecting.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	message := common.NewBusMessage(recipient, notification)
+	data, err := message.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	routingKey := common.BusTopic(s.routingKey, recipient, notification)
+	publishing := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: s.deliveryMode,
+		MessageId:    message.ID,
+		Timestamp:    message.Time,
+		Type:         notification.Event,
+		AppId:        "hookrelay",
+		Headers: amqp.Table{
+			"webhook":  notification.Webhook,
+			"severity": string(notification.Severity),
+		},
+		Body: data,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		reused := s.channel != nil && !s.channel.IsClosed()
+		if !reused {
+			if err := s.connect(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := s.publish(ctx, routingKey, publishing)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, amqp.ErrClosed) || !reused || attempt > 0 {
+			return err
+		}
+		log.FromContext(ctx).Warn().
+			Str("recipient", recipient.Name).
+			Err(err).
+			Msg("AMQP connection lost, reconnecting")
+	}
+}
+
+func (s *Sender) publish(ctx context.Context, routingKey string, publishing amqp.Publishing) error {
+	confirmation, err := s.channel.PublishWithDeferredConfirmWithContext(ctx, s.exchange, routingKey, false, false, publishing)
+	if err != nil {
+		return fmt.Errorf("failed to publish to exchange '%s': %w", s.exchange, err)
+	}
+	if !s.confirm {
+		return nil
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for publish confirmation: %w", err)
+	}
+	if !acked {
+		// a closed channel nacks pending messages, e.g. when the exchange does not exist
+		if s.channel.IsClosed() {
+			return fmt.Errorf("broker closed the channel before confirming the message to exchange '%s'", s.exchange)
+		}
+		return fmt.Errorf("broker rejected the message to exchange '%s'", s.exchange)
+	}
+	return nil
+}
+
+// connect opens a channel, on a new connection if the broker closed the previous one,
+// making up to maxReconnects attempts
+func (s *Sender) connect(ctx context.Context) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = s.open(); err == nil {
+			return nil
+		}
+		if attempt >= s.maxReconnects {
+			return err
+		}
+
+		timer := time.NewTimer(s.reconnectWait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, last error: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Sender) open() error {
+	if s.conn == nil || s.conn.IsClosed() {
+		s.close()
+		conn, err := amqp.DialConfig(s.url, amqp.Config{
+			Dial:       amqp.DefaultDial(s.timeout),
+			Properties: amqp.Table{"connection_name": "hookrelay"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to AMQP broker: %w", err)
+		}
+		s.conn = conn
+	}
+
+	channel, err := s.conn.Channel()
+	if err != nil {
+		s.close()
+		return fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	if s.confirm {
+		if err := channel.Confirm(false); err != nil {
+			s.close()
+			return fmt.Errorf("failed to enable publisher confirms: %w", err)
+		}
+	}
+	s.channel = channel
+	return nil
+}
+
+// close drops the connection without waiting long for a broker that may be gone
+func (s *Sender) close() {
+	if s.conn != nil {
+		s.conn.CloseDeadline(time.Now().Add(closeTimeout))
+	}
+	s.conn, s.channel = nil, nil
+}
+
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.close()
+	return nil
+}
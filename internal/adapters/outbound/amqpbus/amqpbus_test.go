@@ -0,0 +1,291 @@
+package amqpbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// broker is a fake AMQP 0-9-1 broker handling the handshake, channels, publisher confirms and publishes
+type broker struct {
+	listener  net.Listener
+	nack      bool // answers publishes with basic.nack
+	dropAfter int  // closes a connection after this many publishes if positive
+
+	mu        sync.Mutex
+	conns     int
+	published []publishing
+}
+
+type publishing struct {
+	exchange, routingKey string
+	body                 []byte
+}
+
+const frameEnd = 0xCE
+
+func newBroker(t *testing.T, configure func(*broker)) *broker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{listener: listener}
+	if configure != nil {
+		configure(b)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns++
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) url() string {
+	return "amqp://guest:guest@" + b.listener.Addr().String() + "/"
+}
+
+func (b *broker) result() (conns int, published []publishing) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conns, append([]publishing(nil), b.published...)
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil || !bytes.Equal(header, []byte("AMQP\x00\x00\x09\x01")) {
+		return
+	}
+	send := func(channel uint16, class, method uint16, args ...[]byte) {
+		payload := binary.BigEndian.AppendUint16(nil, class)
+		payload = binary.BigEndian.AppendUint16(payload, method)
+		for _, arg := range args {
+			payload = append(payload, arg...)
+		}
+		frame := []byte{1}
+		frame = binary.BigEndian.AppendUint16(frame, channel)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+		conn.Write(append(append(frame, payload...), frameEnd))
+	}
+	send(0, 10, 10, []byte{0, 9}, table(), longstr("PLAIN"), longstr("en_US")) // connection.start
+
+	var current *publishing
+	var bodySize uint64
+	tags := make(map[uint16]uint64) // delivery tag per channel
+	for {
+		typ, channel, payload, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case 2: // content header
+			bodySize = binary.BigEndian.Uint64(payload[4:12])
+		case 3: // content body
+			current.body = append(current.body, payload...)
+			if uint64(len(current.body)) < bodySize {
+				continue
+			}
+			b.mu.Lock()
+			b.published = append(b.published, *current)
+			count := len(b.published)
+			b.mu.Unlock()
+
+			tags[channel]++
+			ack := append(binary.BigEndian.AppendUint64(nil, tags[channel]), 0)
+			if b.nack {
+				send(channel, 60, 120, ack) // basic.nack
+			} else {
+				send(channel, 60, 80, ack) // basic.ack
+			}
+			if b.dropAfter > 0 && count%b.dropAfter == 0 {
+				return
+			}
+		case 1: // method
+			class, method := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+			switch {
+			case class == 10 && method == 11: // connection.start-ok
+				send(0, 10, 30, []byte{0, 0}, binary.BigEndian.AppendUint32(nil, 131072), []byte{0, 0}) // connection.tune
+			case class == 10 && method == 40: // connection.open
+				send(0, 10, 41, shortstr(""))
+			case class == 10 && method == 50: // connection.close
+				send(0, 10, 51)
+				return
+			case class == 20 && method == 10: // channel.open
+				send(channel, 20, 11, longstr(""))
+			case class == 20 && method == 40: // channel.close
+				send(channel, 20, 41)
+			case class == 85 && method == 10: // confirm.select
+				send(channel, 85, 11)
+			case class == 60 && method == 40: // basic.publish
+				args := payload[6:] // after the reserved short
+				exchange, args := readShortstr(args)
+				routingKey, _ := readShortstr(args)
+				current = &publishing{exchange: exchange, routingKey: routingKey}
+			}
+		}
+	}
+}
+
+func readFrame(r io.Reader) (typ byte, channel uint16, payload []byte, err error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	payload = make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[0], binary.BigEndian.Uint16(header[1:]), payload[:len(payload)-1], nil
+}
+
+func table() []byte { return []byte{0, 0, 0, 0} }
+
+func shortstr(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+
+func longstr(s string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+func readShortstr(b []byte) (string, []byte) {
+	n := int(b[0])
+	return string(b[1 : 1+n]), b[1+n:]
+}
+
+var notification = domain.Notification{Title: "Push", Body: "*main* updated", Webhook: "ci", Event: "push"}
+
+func newTestSender(t *testing.T, b *broker, settings Settings) *Sender {
+	t.Helper()
+	settings.URL = b.url()
+	if err := Validate(settings); err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSender(settings)
+	t.Cleanup(func() { sender.Close() })
+	return sender
+}
+
+func TestSendPublishesConfirmed(t *testing.T) {
+	b := newBroker(t, nil)
+	sender := newTestSender(t, b, Settings{Exchange: "events", RoutingKey: "{webhook}.{event}"})
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+			t.Fatalf("Send() #%d: %v", i+1, err)
+		}
+	}
+
+	conns, published := b.result()
+	if conns != 1 || len(published) != 2 {
+		t.Fatalf("%d publishes over %d connections, want 2 over 1", len(published), conns)
+	}
+	if published[0].exchange != "events" || published[0].routingKey != "ci.push" {
+		t.Errorf("published to %q with key %q", published[0].exchange, published[0].routingKey)
+	}
+	var message common.BusMessage
+	if err := json.Unmarshal(published[0].body, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Recipient != "archive" || message.Event.Name != "push" {
+		t.Errorf("message = %+v", message)
+	}
+}
+
+func TestSendReconnectsAfterDroppedConnection(t *testing.T) {
+	b := newBroker(t, func(b *broker) { b.dropAfter = 1 })
+	sender := newTestSender(t, b, Settings{Exchange: "events", ReconnectWait: 10 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), config.Recipient{Name: "archive", Target: "audit"}, notification); err != nil {
+			t.Fatalf("Send() #%d: %v", i+1, err)
+		}
+		// a publish racing the close would be nacked by the client library instead
+		waitClosed(t, sender)
+	}
+
+	conns, published := b.result()
+	if conns != 2 || len(published) != 2 || published[1].routingKey != "audit" {
+		t.Errorf("%d publishes over %d connections, want 2 over 2 to the recipient key", len(published), conns)
+	}
+}
+
+func waitClosed(t *testing.T, sender *Sender) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		sender.mu.Lock()
+		closed := sender.conn == nil || sender.conn.IsClosed()
+		sender.mu.Unlock()
+		if closed {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("connection is still open")
+}
+
+func TestSendReportsRejectedMessage(t *testing.T) {
+	b := newBroker(t, func(b *broker) { b.nack = true })
+	sender := newTestSender(t, b, Settings{Exchange: "events"})
+
+	err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification)
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Send() = %v, want a rejection", err)
+	}
+}
+
+func TestSendGivesUpConnecting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			attempts <- struct{}{}
+			conn.Close()
+		}
+	}()
+	defer listener.Close()
+
+	sender := NewSender(Settings{
+		URL:           "amqp://" + listener.Addr().String() + "/",
+		MaxReconnects: 2,
+		ReconnectWait: 10 * time.Millisecond,
+	})
+	defer sender.Close()
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err == nil {
+		t.Fatal("Send() to a broker closing every connection succeeded")
+	}
+	if n := len(attempts); n != 2 {
+		t.Errorf("%d connection attempts, want 2", n)
+	}
+}
@@ -0,0 +1,160 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	defaultTimeout       = 30 * time.Second
+	defaultMaxConcurrent = 4
+
+	// waitDelay bounds the wait for the output pipes after the command was killed,
+	// in case a child process it started still holds them
+	waitDelay = 5 * time.Second
+	// stderrLimit is how much of the error output goes into the error
+	stderrLimit = 4096
+)
+
+var _ ports.Notifier = (*Sender)(nil)
+
+// Sender runs a command per notification with the message as JSON on stdin and its fields in
+// HOOKRELAY_* environment variables. A non-zero exit code fails the delivery.
+type Sender struct {
+	command string
+	args    []string
+	dir     string
+	env     []string
+	timeout time.Duration
+	slots   chan struct{} // limits the commands running at the same time
+}
+
+// Validate checks the settings without looking for the command
+func Validate(cfg Settings) error {
+	if cfg.Command == "" {
+		return fmt.Errorf("empty 'command' value")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) (*Sender, error) {
+	command, err := exec.LookPath(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("command '%s' not found: %w", cfg.Command, err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+
+	env := make([]string, 0, len(cfg.Env))
+	for name, value := range cfg.Env {
+		env = append(env, strings.ToUpper(name)+"="+value)
+	}
+
+	return &Sender{
+		command: command,
+		args:    cfg.Args,
+		dir:     cfg.Dir,
+		env:     env,
+		timeout: timeout,
+		slots:   make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	message := common.NewBusMessage(recipient, notification)
+	stdin, err := message.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return fmt.Errorf("no free slot to run the command: %w", ctx.Err())
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	replacer := strings.NewReplacer(
+		"{target}", recipient.Target,
+		"{webhook}", notification.Webhook,
+		"{event}", notification.Event,
+	)
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	cmd := exec.CommandContext(runCtx, s.command, args...)
+	cmd.Dir = s.dir
+	cmd.Env = append(append(os.Environ(), s.env...), messageEnv(recipient, message)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stderr := common.NewLimitedBuffer(stderrLimit)
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	common.KillProcessGroup(cmd)
+
+	err = cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	output := strings.TrimSpace(stderr.String())
+	if runCtx.Err() != nil {
+		return fmt.Errorf("command '%s' timed out after %s, stderr: %s", s.command, s.timeout, output)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("command '%s' exited with code %d, stderr: %s", s.command, exitErr.ExitCode(), output)
+	}
+	return fmt.Errorf("failed to run command '%s': %w", s.command, err)
+}
+
+// messageEnv describes the message in environment variables for scripts that do not parse JSON
+func messageEnv(recipient config.Recipient, message common.BusMessage) []string {
+	vars := map[string]string{
+		"HOOKRELAY_ID":        message.ID,
+		"HOOKRELAY_RECIPIENT": message.Recipient,
+		"HOOKRELAY_TARGET":    recipient.Target,
+		"HOOKRELAY_WEBHOOK":   message.Event.Webhook,
+		"HOOKRELAY_EVENT":     message.Event.Name,
+		"HOOKRELAY_SEVERITY":  string(message.Event.Severity),
+		"HOOKRELAY_ENTITY":    message.Event.Entity,
+		"HOOKRELAY_TITLE":     message.Notification.Title,
+		"HOOKRELAY_BODY":      message.Notification.Body,
+		"HOOKRELAY_TEXT":      message.Notification.Text,
+	}
+	if len(message.Notification.Buttons) > 0 {
+		vars["HOOKRELAY_URL"] = message.Notification.Buttons[0].URL
+	}
+
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
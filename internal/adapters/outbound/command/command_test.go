@@ -0,0 +1,234 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// helperEnv makes the test binary act as the command instead of running the tests,
+// its first argument selects what it does
+const helperEnv = "HOOKRELAY_TEST_COMMAND"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "" {
+		os.Exit(m.Run())
+	}
+	runCommand(os.Args[1:])
+}
+
+// invocation is what the 'record' command writes to the file in $OUTPUT
+type invocation struct {
+	Args  []string          `json:"args"`
+	Dir   string            `json:"dir"`
+	Env   map[string]string `json:"env"`
+	Stdin common.BusMessage `json:"stdin"`
+}
+
+// runCommand records its invocation, fails with a long stderr, sleeps, or hangs with a child
+// process holding its stderr
+func runCommand(args []string) {
+	switch args[0] {
+	case "record":
+		var inv invocation
+		if err := json.NewDecoder(os.Stdin).Decode(&inv.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		inv.Args = args[1:]
+		inv.Dir, _ = os.Getwd()
+		inv.Env = make(map[string]string)
+		for _, kv := range os.Environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			if strings.HasPrefix(name, "HOOKRELAY_") || name == "OUTPUT" || name == "EXTRA" {
+				inv.Env[name] = value
+			}
+		}
+		out, _ := json.Marshal(inv)
+		if err := os.WriteFile(os.Getenv("OUTPUT"), out, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	case "fail":
+		fmt.Fprint(os.Stderr, strings.Repeat("x", 2*stderrLimit))
+		os.Exit(3)
+	case "sleep":
+		time.Sleep(300 * time.Millisecond)
+	case "hang":
+		child := exec.Command(os.Args[0], "sleep-long")
+		child.Stderr = os.Stderr
+		if err := child.Start(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		time.Sleep(time.Minute)
+	case "sleep-long":
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+func newTestSender(t *testing.T, settings Settings) *Sender {
+	t.Helper()
+	settings.Command = os.Args[0]
+	if settings.Env == nil {
+		settings.Env = make(map[string]string)
+	}
+	settings.Env[strings.ToLower(helperEnv)] = "1"
+	// a race-enabled binary sleeps for a second before exiting unless told not to
+	settings.Env["gorace"] = "atexit_sleep_ms=0"
+	sender, err := NewSender(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender
+}
+
+var notification = domain.Notification{
+	Title:     "Build failed",
+	Body:      "*main* is red",
+	Severity:  domain.SeverityCritical,
+	Webhook:   "ci",
+	Event:     "workflow_run",
+	EntityKey: "run-1",
+	Buttons:   []domain.Button{{Text: "Logs", URL: "https://ci.example.com/1"}},
+}
+
+func TestSendPassesMessage(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "invocation.json")
+	sender := newTestSender(t, Settings{
+		Args: []string{"record", "{target}", "{webhook}/{event}"},
+		Dir:  dir,
+		Env:  map[string]string{"output": output, "extra": "value"},
+	})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "pager", Target: "oncall"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inv invocation
+	if err := json.Unmarshal(data, &inv); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(inv.Args, " ") != "oncall ci/workflow_run" {
+		t.Errorf("args = %q, want the placeholders replaced", inv.Args)
+	}
+	if realDir, _ := filepath.EvalSymlinks(dir); inv.Dir != dir && inv.Dir != realDir {
+		t.Errorf("dir = %s, want %s", inv.Dir, dir)
+	}
+	if inv.Stdin.Recipient != "pager" || inv.Stdin.Event.Name != "workflow_run" || inv.Stdin.Notification.Text != "main is red" {
+		t.Errorf("stdin = %+v", inv.Stdin)
+	}
+
+	want := map[string]string{
+		"HOOKRELAY_ID":        inv.Stdin.ID,
+		"HOOKRELAY_RECIPIENT": "pager",
+		"HOOKRELAY_TARGET":    "oncall",
+		"HOOKRELAY_WEBHOOK":   "ci",
+		"HOOKRELAY_EVENT":     "workflow_run",
+		"HOOKRELAY_SEVERITY":  "critical",
+		"HOOKRELAY_ENTITY":    "run-1",
+		"HOOKRELAY_TITLE":     "Build failed",
+		"HOOKRELAY_BODY":      "*main* is red",
+		"HOOKRELAY_TEXT":      "main is red",
+		"HOOKRELAY_URL":       "https://ci.example.com/1",
+		"EXTRA":               "value",
+	}
+	for name, value := range want {
+		if inv.Env[name] != value {
+			t.Errorf("$%s = %q, want %q", name, inv.Env[name], value)
+		}
+	}
+	if inv.Stdin.ID == "" {
+		t.Error("empty message id")
+	}
+}
+
+func TestSendReportsExitCodeAndStderr(t *testing.T) {
+	sender := newTestSender(t, Settings{Args: []string{"fail"}})
+
+	err := sender.Send(context.Background(), config.Recipient{Name: "pager"}, notification)
+	if err == nil || !strings.Contains(err.Error(), "exited with code 3, stderr: ") {
+		t.Fatalf("Send() = %v, want the exit code and stderr", err)
+	}
+	_, stderr, _ := strings.Cut(err.Error(), "stderr: ")
+	if stderr != strings.Repeat("x", stderrLimit)+"..." {
+		t.Errorf("stderr in the error has %d bytes, want it cut at %d", len(stderr), stderrLimit)
+	}
+}
+
+func TestSendKillsProcessGroupOnTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are killed on unix only")
+	}
+	sender := newTestSender(t, Settings{Args: []string{"hang"}, Timeout: 500 * time.Millisecond})
+
+	start := time.Now()
+	err := sender.Send(context.Background(), config.Recipient{Name: "pager"}, notification)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 500ms") {
+		t.Errorf("Send() = %v, want a timeout", err)
+	}
+	// a child left running would hold stderr open until waitDelay runs out
+	if elapsed := time.Since(start); elapsed >= waitDelay {
+		t.Errorf("Send() took %s, want the child process killed with the command", elapsed)
+	}
+}
+
+func TestSendLimitsConcurrency(t *testing.T) {
+	sender := newTestSender(t, Settings{Args: []string{"sleep"}, MaxConcurrent: 2})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sender.Send(context.Background(), config.Recipient{Name: "pager"}, notification); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// four commands of 300ms in two slots run in two rounds
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Errorf("4 commands took %s, want at least two rounds", elapsed)
+	}
+}
+
+func TestSendGivesUpWaitingForSlot(t *testing.T) {
+	sender := newTestSender(t, Settings{Args: []string{"sleep"}, MaxConcurrent: 1})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sender.Send(context.Background(), config.Recipient{Name: "pager"}, notification)
+	}()
+	// let the first command take the slot
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := sender.Send(ctx, config.Recipient{Name: "pager"}, notification)
+	if err == nil || !strings.Contains(err.Error(), "no free slot") {
+		t.Errorf("Send() = %v, want no free slot", err)
+	}
+	<-done
+}
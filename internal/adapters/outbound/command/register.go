@@ -0,0 +1,29 @@
+package command
+
+import (
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "exec"
+
+type Settings struct {
+	Command       string            `mapstructure:"command"`        // executable, looked up in PATH unless it contains a slash
+	Args          []string          `mapstructure:"args"`           // may contain '{target}', '{webhook}' and '{event}'
+	Dir           string            `mapstructure:"dir"`            // working directory, the one of hookrelay by default
+	Env           map[string]string `mapstructure:"env"`            // added to the environment of hookrelay, names are upper-cased
+	Timeout       time.Duration     `mapstructure:"timeout"`        // the command is killed after it, '30s' by default
+	MaxConcurrent int               `mapstructure:"max_concurrent"` // commands running at the same time, 4 by default
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings)
+		},
+	})
+}
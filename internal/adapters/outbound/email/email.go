@@ -2,51 +2,205 @@ package email
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/shanth1/gotools/log"
 	"github.com/shanth1/hookrelay/internal/config"
 	"github.com/shanth1/hookrelay/internal/core/domain"
 	"github.com/shanth1/hookrelay/internal/core/ports"
 )
 
-var _ ports.Notifier = (*Sender)(nil)
+var _ ports.BatchNotifier = (*Sender)(nil)
 
 type Sender struct {
-	cfg  config.EmailSettings
-	auth smtp.Auth
+	from   *mail.Address
+	mailer *mailer
+	store  ports.Store
 }
 
-func NewSender(cfg config.EmailSettings) *Sender {
-	auth := smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+// Validate checks the settings without connecting to the server
+func Validate(cfg Settings) error {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return fmt.Errorf("invalid sender address '%s': %w", cfg.From, err)
+	}
+	switch cfg.Security {
+	case "", SecurityTLS, SecurityStartTLS, SecurityOpportunistic, SecurityNone:
+	default:
+		return fmt.Errorf("unknown security '%s'", cfg.Security)
+	}
+	switch cfg.Auth {
+	case "", AuthPlain, AuthLogin, AuthCRAMMD5, AuthNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown auth mechanism '%s'", cfg.Auth)
+	}
+}
+
+// ValidateRecipient checks that the target is a bare address, which is what the server receives in RCPT TO
+func ValidateRecipient(recipient config.Recipient) error {
+	addr, err := mail.ParseAddress(recipient.Target)
+	if err != nil {
+		return fmt.Errorf("invalid address '%s': %w", recipient.Target, err)
+	}
+	if addr.Address != recipient.Target {
+		return fmt.Errorf("invalid address '%s', expected a bare address such as '%s'", recipient.Target, addr.Address)
+	}
+	if len(recipient.Options) > 0 {
+		return fmt.Errorf("unknown options, email recipients have none")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings, store ports.Store) (*Sender, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address '%s': %w", cfg.From, err)
+	}
+
+	security := cfg.Security
+	if security == "" {
+		security = SecurityOpportunistic
+		if cfg.Port == 465 {
+			security = SecurityTLS
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := newAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+
 	return &Sender{
-		cfg:  cfg,
-		auth: auth,
+		from: from,
+		mailer: &mailer{
+			addr:        net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			host:        cfg.Host,
+			security:    security,
+			tlsConfig:   tlsConfig,
+			auth:        auth,
+			timeout:     timeout,
+			idleTimeout: idleTimeout,
+		},
+		store: store,
+	}, nil
+}
+
+func newTLSConfig(cfg Settings) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.Host,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
 	}
+	if cfg.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file '%s'", cfg.CAFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
 }
 
-func (s *Sender) Send(ctx context.Context, recipientEmail string, notification domain.Notification) error {
-	subject := "Webhook Notification"
-	msg := []byte("From: " + s.cfg.From + "\r\n" +
-		"To: " + recipientEmail + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		notification.Body)
+func newAuth(cfg Settings) (smtp.Auth, error) {
+	mechanism := cfg.Auth
+	if mechanism == "" {
+		mechanism = AuthNone
+		if cfg.Username != "" {
+			mechanism = AuthPlain
+		}
+	}
 
-	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
+	switch mechanism {
+	case AuthPlain:
+		return smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host), nil
+	case AuthLogin:
+		return &loginAuth{username: cfg.Username, password: cfg.Password, host: cfg.Host}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(cfg.Username, cfg.Password), nil
+	case AuthNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown auth mechanism '%s'", mechanism)
+	}
+}
 
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- smtp.SendMail(addr, s.auth, s.cfg.From, []string{recipientEmail}, msg)
-	}()
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	return s.SendBatch(ctx, []config.Recipient{recipient}, notification)[recipient.Name]
+}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-errChan:
-		if err != nil {
-			return fmt.Errorf("failed to send email via SMTP: %w", err)
+// SendBatch sends one email to all recipients in a single SMTP transaction
+func (s *Sender) SendBatch(ctx context.Context, recipients []config.Recipient, notification domain.Notification) map[string]error {
+	failed := make(map[string]error)
+	failAll := func(err error) map[string]error {
+		for _, recipient := range recipients {
+			failed[recipient.Name] = err
+		}
+		return failed
+	}
+
+	var targets []string
+	for _, recipient := range recipients {
+		if !slices.Contains(targets, recipient.Target) {
+			targets = append(targets, recipient.Target)
+		}
+	}
+
+	key := threadKey(notification)
+	threadID := s.threadRoot(ctx, key)
+
+	msg, err := buildMessage(s.from, targets, notification, threadID, time.Now())
+	if err != nil {
+		return failAll(fmt.Errorf("failed to build email: %w", err))
+	}
+
+	rejected, err := s.mailer.send(ctx, msg)
+	if err != nil {
+		return failAll(fmt.Errorf("failed to send email via SMTP: %w", err))
+	}
+	for _, recipient := range recipients {
+		if err, ok := rejected[recipient.Target]; ok {
+			failed[recipient.Name] = err
+		}
+	}
+	if len(failed) == 0 {
+		failed = nil
+	}
+
+	if key != "" && threadID == "" && len(rejected) < len(targets) {
+		if err := ports.SetExpiring(ctx, s.store, key, msg.id); err != nil {
+			log.FromContext(ctx).Warn().Err(err).Str("key", key).Msg("failed to remember thread root message")
 		}
-		return nil
 	}
+	return failed
 }
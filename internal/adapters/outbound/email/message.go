@@ -0,0 +1,174 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+const (
+	defaultSubject   = "Webhook Notification"
+	maxSubjectLength = 120
+)
+
+type header struct {
+	name, value string
+}
+
+// message is an email ready to be sent over SMTP
+type message struct {
+	from  string   // envelope sender
+	to    []string // envelope recipients
+	id    string   // Message-ID without angle brackets
+	bytes []byte
+}
+
+// buildMessage renders a notification as a multipart/alternative email with a text and an HTML part.
+// A non-empty threadID makes the email a reply to that message. Several recipients are not disclosed to each other.
+func buildMessage(from *mail.Address, to []string, notification domain.Notification, threadID string, now time.Time) (*message, error) {
+	domainPart := addressDomain(from.Address)
+	id, err := newMessageID(domainPart)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	headers := []header{
+		{"From", from.String()},
+		{"To", toHeader(to)},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject(notification))},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + id + ">"},
+	}
+	if threadID != "" {
+		headers = append(headers, header{"In-Reply-To", "<" + threadID + ">"}, header{"References", "<" + threadID + ">"})
+	}
+	if notification.Webhook != "" {
+		headers = append(headers, header{"List-Id", listID(notification.Webhook, domainPart)})
+	}
+	headers = append(headers,
+		header{"Auto-Submitted", "auto-generated"},
+		header{"MIME-Version", "1.0"},
+		header{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": writer.Boundary()})},
+	)
+
+	var msg bytes.Buffer
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.name, h.value)
+	}
+	msg.WriteString("\r\n")
+
+	if err := writePart(writer, "text/plain", textBody(notification)); err != nil {
+		return nil, err
+	}
+	if err := writePart(writer, "text/html", htmlBody(notification)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish multipart body: %w", err)
+	}
+	msg.Write(body.Bytes())
+
+	return &message{from: from.Address, to: to, id: id, bytes: msg.Bytes()}, nil
+}
+
+func toHeader(to []string) string {
+	if len(to) == 1 {
+		return to[0]
+	}
+	return "undisclosed-recipients:;"
+}
+
+func writePart(writer *multipart.Writer, contentType, content string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s part: %w", contentType, err)
+	}
+	encoder := quotedprintable.NewWriter(part)
+	if _, err := encoder.Write([]byte(strings.ReplaceAll(content, "\n", "\r\n"))); err != nil {
+		return fmt.Errorf("failed to write %s part: %w", contentType, err)
+	}
+	return encoder.Close()
+}
+
+// subject returns the rendered title, or the first line of the body for notifications without one
+func subject(notification domain.Notification) string {
+	title := common.PlainTitle(notification)
+	if title == "" {
+		return defaultSubject
+	}
+	return common.Truncate(title, maxSubjectLength)
+}
+
+func textBody(notification domain.Notification) string {
+	text := common.MarkdownToText(notification.Body)
+	for _, button := range notification.Buttons {
+		text += fmt.Sprintf("\n%s: %s", button.Text, button.URL)
+	}
+	return text
+}
+
+func htmlBody(notification domain.Notification) string {
+	var out strings.Builder
+	out.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"></head>\n")
+	out.WriteString(`<body style="font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;font-size:14px;line-height:1.5">`)
+	out.WriteString("\n" + common.MarkdownToHTML(notification.Body) + "\n")
+	if len(notification.Buttons) > 0 {
+		out.WriteString("<p>")
+		for _, button := range notification.Buttons {
+			fmt.Fprintf(&out,
+				`<a href="%s" style="display:inline-block;margin:4px 8px 4px 0;padding:6px 12px;border-radius:4px;background:#2f6feb;color:#fff;text-decoration:none">%s</a>`,
+				html.EscapeString(button.URL), html.EscapeString(button.Text))
+		}
+		out.WriteString("</p>\n")
+	}
+	out.WriteString("</body></html>\n")
+	return out.String()
+}
+
+// listID identifies the webhook as a mailing list so that clients can filter its notifications
+func listID(webhook, domainPart string) string {
+	label := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, webhook)
+	return fmt.Sprintf("%s <%s.%s>", mime.QEncoding.Encode("utf-8", webhook), label, domainPart)
+}
+
+func newMessageID(domainPart string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate message id: %w", err)
+	}
+	return hex.EncodeToString(random) + "@" + domainPart, nil
+}
+
+func addressDomain(address string) string {
+	if _, domainPart, ok := strings.Cut(address, "@"); ok && domainPart != "" {
+		return domainPart
+	}
+	return "localhost"
+}
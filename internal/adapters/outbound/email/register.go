@@ -0,0 +1,37 @@
+package email
+
+import (
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "email"
+
+type Settings struct {
+	Host               string        `mapstructure:"host"`
+	Port               int           `mapstructure:"port"`
+	Username           string        `mapstructure:"username"`
+	Password           string        `mapstructure:"password"`
+	From               string        `mapstructure:"from"`
+	Security           string        `mapstructure:"security"`             // 'tls' (default on port 465), 'starttls', 'opportunistic' (default otherwise) or 'none'
+	Auth               string        `mapstructure:"auth"`                 // 'plain' (default with a username), 'login', 'cram-md5' or 'none'
+	CAFile             string        `mapstructure:"ca_file"`              // PEM bundle trusted in addition to the system roots
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"` // for internal relays with self-signed certificates
+	Timeout            time.Duration `mapstructure:"timeout"`              // bounds dialing and each message, '10s' by default
+	IdleTimeout        time.Duration `mapstructure:"idle_timeout"`         // keeps the connection open for the next message, '30s' by default
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		ValidateRecipient: func(_ Settings, recipient config.Recipient) error {
+			return ValidateRecipient(recipient)
+		},
+		New: func(_ config.NotifierName, settings Settings, env registry.Env) (ports.Notifier, error) {
+			return NewSender(settings, env.Store)
+		},
+	})
+}
@@ -0,0 +1,249 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SecurityTLS           = "tls"           // implicit TLS, usually on port 465
+	SecurityStartTLS      = "starttls"      // STARTTLS is required
+	SecurityOpportunistic = "opportunistic" // STARTTLS if the server offers it
+	SecurityNone          = "none"
+
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthNone    = "none"
+
+	defaultTimeout     = 10 * time.Second
+	defaultIdleTimeout = 30 * time.Second
+	quitTimeout        = 2 * time.Second
+)
+
+// mailer keeps one SMTP connection open between messages, so a burst of notifications shares a
+// single session. The connection is closed after the idle timeout or after any failure.
+type mailer struct {
+	mu          sync.Mutex
+	addr        string
+	host        string
+	security    string
+	tlsConfig   *tls.Config
+	auth        smtp.Auth
+	timeout     time.Duration
+	idleTimeout time.Duration
+
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// send delivers a message, reconnecting once if a reused connection was dropped by the server.
+// It returns the errors of the recipients the server rejected by address.
+func (m *mailer) send(ctx context.Context, msg *message) (map[string]error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reused := m.client != nil
+	if !reused {
+		if err := m.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	rejected, err := m.transmit(ctx, msg)
+	if err != nil && reused && ctx.Err() == nil && !isPermanent(err) {
+		m.close()
+		if err = m.connect(ctx); err == nil {
+			rejected, err = m.transmit(ctx, msg)
+		}
+	}
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+
+	m.lastUsed = time.Now()
+	client := m.client
+	time.AfterFunc(m.idleTimeout, func() { m.closeIdle(client) })
+	return rejected, nil
+}
+
+func (m *mailer) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: m.timeout}
+	var conn net.Conn
+	var err error
+	if m.security == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig}).DialContext(ctx, "tcp", m.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", m.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", m.addr, err)
+	}
+
+	stop := watch(ctx, conn, m.timeout)
+	defer stop()
+
+	client, err := m.handshake(conn)
+	if err != nil {
+		conn.Close()
+		return contextError(ctx, err)
+	}
+	m.conn, m.client = conn, client
+	return nil
+}
+
+// handshake greets the server, upgrades the connection to TLS and authenticates as configured
+func (m *mailer) handshake(conn net.Conn) (*smtp.Client, error) {
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+
+	if m.security == SecurityStartTLS || m.security == SecurityOpportunistic {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && m.security == SecurityStartTLS {
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if ok {
+			if err := client.StartTLS(m.tlsConfig); err != nil {
+				return nil, fmt.Errorf("failed to start TLS: %w", err)
+			}
+		}
+	}
+
+	if m.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return nil, errors.New("SMTP server does not support authentication")
+		}
+		if err := client.Auth(m.auth); err != nil {
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	return client, nil
+}
+
+func (m *mailer) transmit(ctx context.Context, msg *message) (map[string]error, error) {
+	stop := watch(ctx, m.conn, m.timeout)
+	defer stop()
+
+	if err := m.client.Reset(); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to reset SMTP session: %w", err))
+	}
+	if err := m.client.Mail(msg.from); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("MAIL FROM rejected: %w", err))
+	}
+
+	// a permanently rejected recipient does not stop the delivery to the others
+	rejected := make(map[string]error)
+	for _, to := range msg.to {
+		err := m.client.Rcpt(to)
+		if err == nil {
+			continue
+		}
+		if !isPermanent(err) {
+			return nil, contextError(ctx, fmt.Errorf("RCPT TO <%s> failed: %w", to, err))
+		}
+		rejected[to] = fmt.Errorf("RCPT TO rejected: %w", err)
+	}
+	if len(rejected) == len(msg.to) {
+		return rejected, nil
+	}
+
+	w, err := m.client.Data()
+	if err != nil {
+		return nil, contextError(ctx, fmt.Errorf("DATA rejected: %w", err))
+	}
+	if _, err := w.Write(msg.bytes); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to write message: %w", err))
+	}
+	if err := w.Close(); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("message rejected: %w", err))
+	}
+	return rejected, nil
+}
+
+// closeIdle quits the connection if it is still the given one and has not been used since
+func (m *mailer) closeIdle(client *smtp.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != client || time.Since(m.lastUsed) < m.idleTimeout {
+		return
+	}
+	m.conn.SetDeadline(time.Now().Add(quitTimeout))
+	m.client.Quit()
+	m.close()
+}
+
+func (m *mailer) close() {
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.conn, m.client = nil, nil
+}
+
+// watch bounds the SMTP exchange on conn by the timeout and interrupts it when ctx is cancelled
+func watch(ctx context.Context, conn net.Conn, timeout time.Duration) (stop func() bool) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	return context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+}
+
+// contextError reports the cancellation of ctx instead of the I/O error it caused
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// isPermanent reports a 5xx reply, which a new connection would not change
+func isPermanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge '%s'", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,356 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/storage"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// smtpServer is a fake SMTP server speaking just enough of the protocol for net/smtp
+type smtpServer struct {
+	t         *testing.T
+	listener  net.Listener
+	tlsConfig *tls.Config     // offers STARTTLS if set
+	auth      bool            // offers AUTH PLAIN and LOGIN
+	reject    map[string]bool // recipients answered with 550
+	dropAfter int             // closes a connection after this many messages if positive
+
+	mu       sync.Mutex
+	conns    int
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from     string
+	to       []string
+	data     string
+	tls      bool
+	username string
+	password string
+}
+
+// newSMTPServer starts the server on a random port, with implicit TLS if implicitTLS is set
+func newSMTPServer(t *testing.T, implicitTLS bool, configure func(*smtpServer)) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{t: t, listener: listener, reject: make(map[string]bool)}
+	if configure != nil {
+		configure(s)
+	}
+	if implicitTLS {
+		s.listener = tls.NewListener(listener, testTLSConfig(t))
+	}
+	t.Cleanup(func() { s.listener.Close() })
+
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn, implicitTLS)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) settings() Settings {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return Settings{Host: host, Port: p, From: "relay@example.com", Security: SecurityNone, InsecureSkipVerify: true}
+}
+
+func (s *smtpServer) serve(conn net.Conn, secure bool) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			conn.Write([]byte(line + "\r\n"))
+		}
+	}
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err == nil
+	}
+
+	var msg smtpMessage
+	delivered := 0
+	reply("220 localhost ESMTP fake")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			lines := []string{"250-localhost"}
+			if s.tlsConfig != nil && !secure {
+				lines = append(lines, "250-STARTTLS")
+			}
+			if s.auth {
+				lines = append(lines, "250-AUTH PLAIN LOGIN")
+			}
+			reply(append(lines, "250 8BITMIME")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			switch mechanism {
+			case "PLAIN":
+				decoded, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(decoded), "\x00")
+				if len(parts) == 3 {
+					msg.username, msg.password = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				username, _ := readLine()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				password, _ := readLine()
+				u, _ := base64.StdEncoding.DecodeString(username)
+				p, _ := base64.StdEncoding.DecodeString(password)
+				msg.username, msg.password = string(u), string(p)
+			}
+			reply("235 authenticated")
+		case "RSET", "NOOP":
+			msg = smtpMessage{username: msg.username, password: msg.password}
+			reply("250 OK")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if s.reject[to] {
+				reply("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, to)
+			reply("250 OK")
+		case "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, ok := readLine()
+				if !ok {
+					return
+				}
+				if line == "." {
+					break
+				}
+				data.WriteString(line + "\n")
+			}
+			msg.data, msg.tls = data.String(), secure
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+			if delivered++; s.dropAfter > 0 && delivered >= s.dropAfter {
+				return
+			}
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) result() (conns int, messages []smtpMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, append([]smtpMessage(nil), s.messages...)
+}
+
+// testTLSConfig returns a server config with a self-signed certificate for 127.0.0.1
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func newTestSender(t *testing.T, settings Settings) *Sender {
+	t.Helper()
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(settings, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender
+}
+
+func recipient(name, target string) config.Recipient {
+	return config.Recipient{Name: name, Target: target}
+}
+
+var testNotification = domain.Notification{Title: "Build failed", Body: "main is red", Webhook: "ci"}
+
+func TestSenderReusesConnection(t *testing.T) {
+	server := newSMTPServer(t, false, nil)
+	sender := newTestSender(t, server.settings())
+
+	for i := 0; i < 3; i++ {
+		if err := sender.Send(context.Background(), recipient("ops", "ops@example.com"), testNotification); err != nil {
+			t.Fatalf("Send() #%d: %v", i+1, err)
+		}
+	}
+
+	conns, messages := server.result()
+	if conns != 1 || len(messages) != 3 {
+		t.Errorf("%d messages over %d connections, want 3 over 1", len(messages), conns)
+	}
+}
+
+func TestSenderReconnectsAfterDroppedConnection(t *testing.T) {
+	server := newSMTPServer(t, false, func(s *smtpServer) { s.dropAfter = 1 })
+	sender := newTestSender(t, server.settings())
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), recipient("ops", "ops@example.com"), testNotification); err != nil {
+			t.Fatalf("Send() #%d: %v", i+1, err)
+		}
+	}
+
+	conns, messages := server.result()
+	if conns != 2 || len(messages) != 2 {
+		t.Errorf("%d messages over %d connections, want 2 over 2", len(messages), conns)
+	}
+}
+
+func TestSenderReportsRejectedRecipients(t *testing.T) {
+	server := newSMTPServer(t, false, func(s *smtpServer) { s.reject["gone@example.com"] = true })
+	sender := newTestSender(t, server.settings())
+
+	failed := sender.SendBatch(context.Background(), []config.Recipient{
+		recipient("ops", "ops@example.com"),
+		recipient("gone", "gone@example.com"),
+	}, testNotification)
+
+	if len(failed) != 1 || failed["gone"] == nil {
+		t.Errorf("SendBatch() = %v, want only 'gone' to fail", failed)
+	}
+	_, messages := server.result()
+	if len(messages) != 1 || strings.Join(messages[0].to, ",") != "ops@example.com" {
+		t.Errorf("messages = %+v, want one to ops@example.com", messages)
+	}
+}
+
+func TestSenderSecurity(t *testing.T) {
+	tests := []struct {
+		name        string
+		implicitTLS bool
+		startTLS    bool // the server offers STARTTLS
+		security    string
+		wantErr     string
+		wantTLS     bool
+	}{
+		{name: "implicit tls", implicitTLS: true, security: SecurityTLS, wantTLS: true},
+		{name: "required starttls", startTLS: true, security: SecurityStartTLS, wantTLS: true},
+		{name: "required starttls not offered", security: SecurityStartTLS, wantErr: "does not support STARTTLS"},
+		{name: "opportunistic starttls", startTLS: true, security: SecurityOpportunistic, wantTLS: true},
+		{name: "opportunistic plain", security: SecurityOpportunistic},
+		{name: "plain", startTLS: true, security: SecurityNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPServer(t, tt.implicitTLS, func(s *smtpServer) {
+				if tt.startTLS {
+					s.tlsConfig = testTLSConfig(t)
+				}
+			})
+			settings := server.settings()
+			settings.Security = tt.security
+			sender := newTestSender(t, settings)
+
+			err := sender.Send(context.Background(), recipient("ops", "ops@example.com"), testNotification)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() = %v", err)
+			}
+			if _, messages := server.result(); len(messages) != 1 || messages[0].tls != tt.wantTLS {
+				t.Errorf("messages = %+v, want one sent with TLS %v", messages, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestSenderAuth(t *testing.T) {
+	for _, mechanism := range []string{AuthPlain, AuthLogin} {
+		t.Run(mechanism, func(t *testing.T) {
+			server := newSMTPServer(t, false, func(s *smtpServer) { s.auth = true })
+			settings := server.settings()
+			settings.Auth, settings.Username, settings.Password = mechanism, "relay", "secret"
+			sender := newTestSender(t, settings)
+
+			if err := sender.Send(context.Background(), recipient("ops", "ops@example.com"), testNotification); err != nil {
+				t.Fatalf("Send() = %v", err)
+			}
+			_, messages := server.result()
+			if len(messages) != 1 || messages[0].username != "relay" || messages[0].password != "secret" {
+				t.Errorf("messages = %+v, want one sent as relay:secret", messages)
+			}
+		})
+	}
+}
+
+func TestSenderFailsWithoutServerAuth(t *testing.T) {
+	server := newSMTPServer(t, false, nil)
+	settings := server.settings()
+	settings.Username, settings.Password = "relay", "secret"
+	sender := newTestSender(t, settings)
+
+	err := sender.Send(context.Background(), recipient("ops", "ops@example.com"), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "does not support authentication") {
+		t.Errorf("Send() = %v, want an authentication error", err)
+	}
+}
@@ -0,0 +1,35 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// threadKey returns the store key of the first email sent about an entity, empty if the
+// notification is not about a PR, issue or task. The key is shared by all recipients, as a
+// reply threads with the messages that reference the same root even without the root itself.
+func threadKey(notification domain.Notification) string {
+	if notification.EntityKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("email:%s:%s", notification.Webhook, notification.EntityKey)
+}
+
+// threadRoot returns the Message-ID that later emails about the entity reply to, empty for the first one
+func (s *Sender) threadRoot(ctx context.Context, key string) string {
+	if key == "" {
+		return ""
+	}
+	messageID, ok, err := s.store.Get(ctx, key)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Str("key", key).Msg("failed to look up thread root message")
+		return ""
+	}
+	if !ok {
+		return ""
+	}
+	return messageID
+}
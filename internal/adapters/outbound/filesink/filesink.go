@@ -0,0 +1,265 @@
+package filesink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	defaultMaxSizeMB = 100
+	backupTimeFormat = "20060102T150405.000"
+)
+
+var _ ports.Notifier = (*Sender)(nil)
+
+// Sender appends notifications as JSON lines to files that rotate by size and age
+type Sender struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu    sync.Mutex
+	files map[string]*rotatingFile // by path
+}
+
+func Validate(cfg Settings) error {
+	switch {
+	case cfg.MaxSizeMB < 0:
+		return fmt.Errorf("negative 'max_size_mb' value")
+	case cfg.MaxAge < 0:
+		return fmt.Errorf("negative 'max_age' value")
+	case cfg.MaxBackups < 0:
+		return fmt.Errorf("negative 'max_backups' value")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	maxSizeMB := cfg.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	return &Sender{
+		path:       cfg.Path,
+		maxSize:    int64(maxSizeMB) << 20,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+		files:      make(map[string]*rotatingFile),
+	}
+}
+
+// record is one line of the file
+type record struct {
+	Time      time.Time       `json:"time"`
+	Recipient string          `json:"recipient"`
+	Webhook   string          `json:"webhook,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Severity  domain.Severity `json:"severity,omitempty"`
+	Entity    string          `json:"entity,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Body      string          `json:"body"`
+	Buttons   []domain.Button `json:"buttons,omitempty"`
+}
+
+// Send appends the notification to the file in recipient.Target, or in the settings path
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	path := s.path
+	if recipient.Target != "" {
+		path = recipient.Target
+	}
+	if path == "" {
+		return fmt.Errorf("recipient '%s' has no file path", recipient.Name)
+	}
+
+	line, err := json.Marshal(record{
+		Time:      time.Now().UTC(),
+		Recipient: recipient.Name,
+		Webhook:   notification.Webhook,
+		Event:     notification.Event,
+		Severity:  notification.Severity,
+		Entity:    notification.EntityKey,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Buttons:   notification.Buttons,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	return s.file(path).write(append(line, '\n'))
+}
+
+func (s *Sender) file(path string) *rotatingFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if !ok {
+		f = &rotatingFile{path: path, maxSize: s.maxSize, maxAge: s.maxAge, maxBackups: s.maxBackups}
+		s.files[path] = f
+	}
+	return f
+}
+
+// Close closes the open files, a later notification opens them again
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, f := range s.files {
+		errs = append(errs, f.close())
+	}
+	return errors.Join(errs...)
+}
+
+// rotatingFile renames itself to '<name>-<time><ext>' when it gets too large or too old
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu        sync.Mutex
+	file      *os.File
+	size      int64
+	startedAt time.Time // of the first line, so that restarts do not postpone rotation by age
+}
+
+func (f *rotatingFile) write(line []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	tooLarge := f.size > 0 && f.size+int64(len(line)) > f.maxSize
+	tooOld := f.maxAge > 0 && time.Since(f.startedAt) > f.maxAge
+	if tooLarge || tooOld {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %w", f.path, err)
+	}
+	return nil
+}
+
+func (f *rotatingFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for '%s': %w", f.path, err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat '%s': %w", f.path, err)
+	}
+	f.file, f.size, f.startedAt = file, info.Size(), firstLineTime(f.path)
+	return nil
+}
+
+// firstLineTime returns the time of the first record in the file at path, or now for an empty
+// or unreadable file
+func firstLineTime(path string) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Now()
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return time.Now()
+	}
+	var first struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &first); err != nil || first.Time.IsZero() {
+		return time.Now()
+	}
+	return first.Time
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close '%s': %w", f.path, err)
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), time.Now().UTC().Format(backupTimeFormat), ext)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate '%s': %w", f.path, err)
+	}
+	if err := f.prune(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// prune removes the oldest rotated files beyond maxBackups
+func (f *rotatingFile) prune() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return fmt.Errorf("failed to list rotated files of '%s': %w", f.path, err)
+	}
+	// the pattern also matches other files such as 'events-audit.jsonl' for 'events.jsonl'
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	// the timestamps in the names sort chronologically
+	sort.Strings(backups)
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("failed to remove rotated file '%s': %w", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
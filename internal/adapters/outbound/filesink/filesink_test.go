@@ -0,0 +1,178 @@
+package filesink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+var notification = domain.Notification{
+	Title:     "Build failed",
+	Body:      "*main* is red",
+	Severity:  domain.SeverityCritical,
+	Webhook:   "ci",
+	Event:     "workflow_run",
+	EntityKey: "run-1",
+}
+
+func readRecords(t *testing.T, path string) []record {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
+
+func TestSendAppendsRecords(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewSender(Settings{Path: filepath.Join(dir, "events.jsonl")})
+	defer sender.Close()
+
+	for _, recipient := range []config.Recipient{
+		{Name: "archive"},
+		{Name: "audit", Target: filepath.Join(dir, "audit", "events.jsonl")},
+		{Name: "archive"},
+	} {
+		if err := sender.Send(context.Background(), recipient, notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readRecords(t, filepath.Join(dir, "events.jsonl"))
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2", len(records))
+	}
+	r := records[0]
+	if r.Recipient != "archive" || r.Webhook != "ci" || r.Event != "workflow_run" || r.Severity != domain.SeverityCritical ||
+		r.Entity != "run-1" || r.Title != "Build failed" || r.Body != "*main* is red" || r.Time.IsZero() {
+		t.Errorf("record = %+v", r)
+	}
+	if audit := readRecords(t, filepath.Join(dir, "audit", "events.jsonl")); len(audit) != 1 || audit[0].Recipient != "audit" {
+		t.Errorf("audit records = %+v, want one in the target of the recipient", audit)
+	}
+}
+
+func TestSendRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewSender(Settings{Path: filepath.Join(dir, "events.jsonl")})
+	defer sender.Close()
+	sender.maxSize = 300 // a record is about 200 bytes
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := listDir(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("files = %v, want the live file and one backup", names)
+	}
+	for _, name := range names {
+		if records := readRecords(t, filepath.Join(dir, name)); len(records) != 1 {
+			t.Errorf("%s has %d records, want 1", name, len(records))
+		}
+	}
+}
+
+func TestSendRotatesByAgeOfFirstLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+
+	// written by an earlier run two hours ago
+	old, _ := json.Marshal(record{Time: time.Now().Add(-2 * time.Hour).UTC(), Recipient: "archive", Body: "old"})
+	if err := os.WriteFile(path, append(old, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := NewSender(Settings{Path: path, MaxAge: time.Hour})
+	defer sender.Close()
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := readRecords(t, path); len(records) != 1 || records[0].Body != notification.Body {
+		t.Errorf("live records = %+v, want only the new one", records)
+	}
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Errorf("files = %v, want the old file rotated", names)
+	}
+
+	// a restart keeps the age of the new file
+	sender.Close()
+	sender = NewSender(Settings{Path: path, MaxAge: time.Hour})
+	defer sender.Close()
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatal(err)
+	}
+	if records := readRecords(t, path); len(records) != 2 {
+		t.Errorf("%d live records, want 2 without another rotation", len(records))
+	}
+}
+
+func TestPruneKeepsUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"events.jsonl",
+		"events-20240101T000000.000.jsonl",
+		"events-20240102T000000.000.jsonl",
+		"events-20240103T000000.000.jsonl",
+		// the live file and a backup of another sink
+		"events-audit.jsonl",
+		"events-audit-20230101T000000.000.jsonl",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &rotatingFile{path: filepath.Join(dir, "events.jsonl"), maxBackups: 2}
+	if err := f.prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"events-20240102T000000.000.jsonl",
+		"events-20240103T000000.000.jsonl",
+		"events-audit-20230101T000000.000.jsonl",
+		"events-audit.jsonl",
+		"events.jsonl",
+	}
+	if names := listDir(t, dir); !slices.Equal(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,27 @@
+package filesink
+
+import (
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "file"
+
+type Settings struct {
+	Path       string        `mapstructure:"path"`        // JSON lines file, a recipient target overrides it
+	MaxSizeMB  int           `mapstructure:"max_size_mb"` // rotates the file when it would grow past this size, 100 by default
+	MaxAge     time.Duration `mapstructure:"max_age"`     // rotates the file when its first line is older, e.g. '24h'; off by default
+	MaxBackups int           `mapstructure:"max_backups"` // rotated files to keep, all by default
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
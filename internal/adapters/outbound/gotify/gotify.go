@@ -0,0 +1,103 @@
+package gotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// priorities maps severities to Gotify priorities, the Android app alerts from 8
+var priorities = map[domain.Severity]int{
+	domain.SeverityInfo:     4,
+	domain.SeverityWarning:  7,
+	domain.SeverityCritical: 10,
+}
+
+// dialect is the CommonMark Gotify clients render with the text/markdown content type
+var dialect = common.MarkdownDialect{Bold: "**", Italic: "_", Escapes: true}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client    *http.Client
+	serverURL string
+	token     string
+}
+
+func Validate(cfg Settings) error {
+	if cfg.ServerURL == "" {
+		return fmt.Errorf("empty 'server_url' value")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	return &Sender{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		serverURL: strings.TrimRight(cfg.ServerURL, "/"),
+		token:     cfg.Token,
+	}
+}
+
+// Send creates a message with the application token of recipient.Target or of the settings.
+// The first button becomes the click URL of the notification.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	token := s.token
+	if recipient.Target != "" {
+		token = recipient.Target
+	}
+
+	extras := map[string]interface{}{
+		"client::display": map[string]string{"contentType": "text/markdown"},
+	}
+	message := common.MarkdownToDialect(notification.Body, dialect)
+	if len(notification.Buttons) > 0 {
+		extras["client::notification"] = map[string]interface{}{
+			"click": map[string]string{"url": notification.Buttons[0].URL},
+		}
+		links := make([]string, len(notification.Buttons))
+		for i, button := range notification.Buttons {
+			links[i] = fmt.Sprintf("[%s](%s)", button.Text, button.URL)
+		}
+		message += "\n\n" + strings.Join(links, " · ")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"title":    common.PlainTitle(notification),
+		"message":  message,
+		"priority": priorities[notification.Severity],
+		"extras":   extras,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.serverURL+"/message", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gotify API error: status %d, response: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
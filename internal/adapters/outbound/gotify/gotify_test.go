@@ -0,0 +1,101 @@
+package gotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Extras   struct {
+		Display struct {
+			ContentType string `json:"contentType"`
+		} `json:"client::display"`
+		Notification struct {
+			Click struct {
+				URL string `json:"url"`
+			} `json:"click"`
+		} `json:"client::notification"`
+	} `json:"extras"`
+}
+
+func newTestServer(t *testing.T, status int, got *gotifyMessage, key *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" {
+			t.Errorf("path = %s, want /message", r.URL.Path)
+		}
+		*key = r.Header.Get("X-Gotify-Key")
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendCreatesMessage(t *testing.T) {
+	var got gotifyMessage
+	var key string
+	server := newTestServer(t, http.StatusOK, &got, &key)
+
+	sender := NewSender(Settings{ServerURL: server.URL + "/", Token: "app-token"})
+	err := sender.Send(context.Background(), config.Recipient{Name: "ops"}, domain.Notification{
+		Title:    "PR merged",
+		Body:     "*feature* merged",
+		Severity: domain.SeverityWarning,
+		Buttons:  []domain.Button{{Text: "Open", URL: "https://git.example.com/pr/1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key != "app-token" {
+		t.Errorf("X-Gotify-Key = %q, want the settings token", key)
+	}
+	if got.Title != "PR merged" || got.Priority != 7 {
+		t.Errorf("title = %q, priority = %d", got.Title, got.Priority)
+	}
+	if got.Message != "**feature** merged\n\n[Open](https://git.example.com/pr/1)" {
+		t.Errorf("message = %q", got.Message)
+	}
+	if got.Extras.Display.ContentType != "text/markdown" || got.Extras.Notification.Click.URL != "https://git.example.com/pr/1" {
+		t.Errorf("extras = %+v", got.Extras)
+	}
+}
+
+func TestSendUsesRecipientToken(t *testing.T) {
+	var got gotifyMessage
+	var key string
+	server := newTestServer(t, http.StatusOK, &got, &key)
+
+	sender := NewSender(Settings{ServerURL: server.URL, Token: "app-token"})
+	if err := sender.Send(context.Background(), config.Recipient{Target: "other-app"}, domain.Notification{Body: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if key != "other-app" {
+		t.Errorf("X-Gotify-Key = %q, want the recipient target", key)
+	}
+}
+
+func TestSendReportsAPIErrors(t *testing.T) {
+	var got gotifyMessage
+	var key string
+	server := newTestServer(t, http.StatusUnauthorized, &got, &key)
+
+	err := NewSender(Settings{ServerURL: server.URL}).Send(context.Background(), config.Recipient{}, domain.Notification{Body: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Send() = %v, want the status of the server", err)
+	}
+}
@@ -0,0 +1,23 @@
+package gotify
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "gotify"
+
+type Settings struct {
+	ServerURL string `mapstructure:"server_url"`
+	Token     string `mapstructure:"token"` // application token, a recipient target overrides it
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
@@ -0,0 +1,189 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	MsgTypeText   = "m.text"
+	MsgTypeNotice = "m.notice"
+
+	defaultMaxRetries = 3
+	retryBaseDelay    = 500 * time.Millisecond
+	htmlFormat        = "org.matrix.custom.html"
+)
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client        *http.Client
+	homeserverURL string
+	accessToken   string
+	msgType       string
+	maxRetries    int
+
+	txnPrefix  string // distinguishes the transaction ids of this process from earlier runs
+	txnCounter atomic.Uint64
+}
+
+func Validate(cfg Settings) error {
+	if cfg.HomeserverURL == "" {
+		return fmt.Errorf("empty 'homeserver_url' value")
+	}
+	if cfg.AccessToken == "" {
+		return fmt.Errorf("empty 'access_token' value")
+	}
+	switch cfg.MsgType {
+	case "", MsgTypeText, MsgTypeNotice:
+		return nil
+	default:
+		return fmt.Errorf("unsupported msgtype '%s'", cfg.MsgType)
+	}
+}
+
+func NewSender(cfg Settings) *Sender {
+	msgType := cfg.MsgType
+	if msgType == "" {
+		msgType = MsgTypeText
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	return &Sender{
+		client:        &http.Client{Timeout: 10 * time.Second},
+		homeserverURL: strings.TrimRight(cfg.HomeserverURL, "/"),
+		accessToken:   cfg.AccessToken,
+		msgType:       msgType,
+		maxRetries:    maxRetries,
+		txnPrefix:     fmt.Sprintf("hookrelay-%d", time.Now().UnixNano()),
+	}
+}
+
+// roomMessage is the content of an m.room.message event
+type roomMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// Send posts the notification to the room in recipient.Target, e.g. '!abc123:example.com'.
+// Retries reuse the transaction id, so the homeserver delivers the message at most once.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	content := roomMessage{
+		MsgType:       s.msgType,
+		Body:          common.MarkdownToText(notification.Body),
+		Format:        htmlFormat,
+		FormattedBody: common.MarkdownToHTML(notification.Body),
+	}
+	for _, button := range notification.Buttons {
+		content.Body += fmt.Sprintf("\n%s: %s", button.Text, button.URL)
+		content.FormattedBody += fmt.Sprintf(`<br><a href="%s">%s</a>`, html.EscapeString(button.URL), html.EscapeString(button.Text))
+	}
+
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	txnID := fmt.Sprintf("%s-%d", s.txnPrefix, s.txnCounter.Add(1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		s.homeserverURL, url.PathEscape(recipient.Target), url.PathEscape(txnID))
+
+	for attempt := 0; ; attempt++ {
+		err := s.put(ctx, endpoint, payload)
+
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || !apiErr.retryable() || attempt >= s.maxRetries {
+			return err
+		}
+
+		delay := apiErr.RetryAfter
+		if delay <= 0 {
+			delay = retryBaseDelay << attempt
+		}
+		log.FromContext(ctx).Warn().
+			Str("recipient", recipient.Name).
+			Str("retry_in", delay.String()).
+			Err(err).
+			Msg("matrix API call failed, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// apiError is a failed Client-Server API call
+type apiError struct {
+	StatusCode int
+	ErrCode    string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("matrix API error: status %d, %s: %s", e.StatusCode, e.ErrCode, e.Message)
+}
+
+func (e *apiError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+func (s *Sender) put(ctx context.Context, endpoint string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var matrixErr struct {
+		ErrCode      string `json:"errcode"`
+		Error        string `json:"error"`
+		RetryAfterMs int64  `json:"retry_after_ms"`
+	}
+	if err := json.Unmarshal(body, &matrixErr); err != nil {
+		matrixErr.Error = string(body)
+	}
+	return &apiError{
+		StatusCode: resp.StatusCode,
+		ErrCode:    matrixErr.ErrCode,
+		Message:    matrixErr.Error,
+		RetryAfter: time.Duration(matrixErr.RetryAfterMs) * time.Millisecond,
+	}
+}
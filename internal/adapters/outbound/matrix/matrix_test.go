@@ -0,0 +1,204 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// homeserver is a stub of the room send endpoint answering with the queued responses, then with 200
+type homeserver struct {
+	mu        sync.Mutex
+	responses []stubResponse
+	requests  []stubRequest
+}
+
+type stubResponse struct {
+	status int
+	body   string
+}
+
+type stubRequest struct {
+	path    string // unescaped
+	auth    string
+	content roomMessage
+}
+
+func (h *homeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var content roomMessage
+	json.NewDecoder(r.Body).Decode(&content)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, stubRequest{path: r.URL.Path, auth: r.Header.Get("Authorization"), content: content})
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if len(h.responses) == 0 {
+		w.Write([]byte(`{"event_id":"$event"}`))
+		return
+	}
+	resp := h.responses[0]
+	h.responses = h.responses[1:]
+	w.WriteHeader(resp.status)
+	w.Write([]byte(resp.body))
+}
+
+func (h *homeserver) sent() []stubRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]stubRequest(nil), h.requests...)
+}
+
+func newTestSender(t *testing.T, stub *homeserver, maxRetries int) *Sender {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return NewSender(Settings{HomeserverURL: server.URL + "/", AccessToken: "token", MsgType: MsgTypeNotice, MaxRetries: maxRetries})
+}
+
+var room = config.Recipient{Name: "ops", Target: "!room:example.com"}
+
+func TestSendRoomMessage(t *testing.T) {
+	stub := &homeserver{}
+	sender := newTestSender(t, stub, 0)
+
+	err := sender.Send(context.Background(), room, domain.Notification{
+		Body:    "*Build* failed",
+		Buttons: []domain.Button{{Text: "Logs", URL: "https://ci.example.com/1?a=b&c=d"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := stub.sent()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if !strings.HasPrefix(req.path, "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/hookrelay-") {
+		t.Errorf("path = %s", req.path)
+	}
+	if req.auth != "Bearer token" {
+		t.Errorf("Authorization = %q", req.auth)
+	}
+	content := req.content
+	if content.MsgType != MsgTypeNotice || content.Format != htmlFormat {
+		t.Errorf("msgtype = %q, format = %q", content.MsgType, content.Format)
+	}
+	if !strings.Contains(content.Body, "Logs: https://ci.example.com/1?a=b&c=d") {
+		t.Errorf("body = %q, want the button as text", content.Body)
+	}
+	if !strings.Contains(content.FormattedBody, `<a href="https://ci.example.com/1?a=b&amp;c=d">Logs</a>`) {
+		t.Errorf("formatted_body = %q, want the button as an escaped link", content.FormattedBody)
+	}
+}
+
+func TestSendRetriesWithSameTransaction(t *testing.T) {
+	stub := &homeserver{responses: []stubResponse{
+		{http.StatusTooManyRequests, `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":10}`},
+		{http.StatusBadGateway, `{"retry_after_ms":10}`},
+	}}
+	sender := newTestSender(t, stub, 0)
+
+	if err := sender.Send(context.Background(), room, domain.Notification{Body: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := stub.sent()
+	if len(requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(requests))
+	}
+	for _, req := range requests[1:] {
+		if req.path != requests[0].path {
+			t.Errorf("retry path = %s, want the transaction of the first attempt %s", req.path, requests[0].path)
+		}
+	}
+
+	// the next message is a new transaction
+	if err := sender.Send(context.Background(), room, domain.Notification{Body: "again"}); err != nil {
+		t.Fatal(err)
+	}
+	if last := stub.sent()[3]; last.path == requests[0].path {
+		t.Errorf("second message reuses transaction %s", last.path)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRetries   int
+		responses    []stubResponse
+		wantRequests int
+		wantStatus   int
+	}{
+		{
+			name:         "forbidden is not retried",
+			responses:    []stubResponse{{http.StatusForbidden, `{"errcode":"M_FORBIDDEN","error":"not in room"}`}},
+			wantRequests: 1,
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:       "retries run out",
+			maxRetries: 1,
+			responses: []stubResponse{
+				{http.StatusInternalServerError, `{"retry_after_ms":1}`},
+				{http.StatusInternalServerError, `{"retry_after_ms":1}`},
+			},
+			wantRequests: 2,
+			wantStatus:   http.StatusInternalServerError,
+		},
+		{
+			name:         "retries disabled",
+			maxRetries:   -1,
+			responses:    []stubResponse{{http.StatusTooManyRequests, `{"retry_after_ms":1}`}},
+			wantRequests: 1,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &homeserver{responses: tt.responses}
+			sender := newTestSender(t, stub, tt.maxRetries)
+
+			err := sender.Send(context.Background(), room, domain.Notification{Body: "hello"})
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("Send() = %v, want an API error with status %d", err, tt.wantStatus)
+			}
+			if n := len(stub.sent()); n != tt.wantRequests {
+				t.Errorf("%d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  string
+	}{
+		{name: "valid", settings: Settings{HomeserverURL: "https://matrix.example.com", AccessToken: "token"}},
+		{name: "no homeserver", settings: Settings{AccessToken: "token"}, wantErr: "empty 'homeserver_url' value"},
+		{name: "no token", settings: Settings{HomeserverURL: "https://matrix.example.com"}, wantErr: "empty 'access_token' value"},
+		{name: "unknown msgtype", settings: Settings{HomeserverURL: "https://matrix.example.com", AccessToken: "token", MsgType: "m.image"}, wantErr: "unsupported msgtype"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.settings)
+			if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package matrix
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "matrix"
+
+type Settings struct {
+	HomeserverURL string `mapstructure:"homeserver_url"` // e.g. 'https://matrix.example.com'
+	AccessToken   string `mapstructure:"access_token"`
+	MsgType       string `mapstructure:"msgtype"`     // 'm.text' (default) or 'm.notice', which bots are expected to use
+	MaxRetries    int    `mapstructure:"max_retries"` // retries after 429 and 5xx responses, 3 by default; -1 disables retries
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// dialect is the CommonMark flavor Mattermost renders
+var dialect = common.MarkdownDialect{Bold: "**", Italic: "_", Escapes: true}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client *http.Client
+	cfg    Settings
+}
+
+func Validate(cfg Settings) error {
+	if cfg.WebhookURL == "" && (cfg.URL == "" || cfg.Token == "") {
+		return fmt.Errorf("either 'webhook_url' or 'url' and 'token' must be set")
+	}
+	return nil
+}
+
+// ValidateRecipient requires a channel id for the REST API, webhook posts go to the default channel without one
+func ValidateRecipient(cfg Settings, recipient config.Recipient) error {
+	if cfg.WebhookURL == "" && recipient.Target == "" {
+		return fmt.Errorf("empty 'target' value, expected a channel id")
+	}
+	if len(recipient.Options) > 0 {
+		return fmt.Errorf("unknown options, mattermost recipients have none")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &Sender{
+		client: &http.Client{Timeout: 10 * time.Second},
+		cfg:    cfg,
+	}
+}
+
+// attachment is a message attachment, which carries the color of the severity
+type attachment struct {
+	Fallback string `json:"fallback"`
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+}
+
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	text := common.MarkdownToDialect(notification.Body, dialect)
+	if links := buttonLinks(notification.Buttons); links != "" {
+		text += "\n\n" + links
+	}
+	attachments := []attachment{{
+		Fallback: common.MarkdownToText(notification.Body),
+		Color:    common.SeverityColor(notification.Severity),
+		Text:     text,
+	}}
+
+	if s.cfg.WebhookURL != "" {
+		payload := map[string]interface{}{"attachments": attachments}
+		if recipient.Target != "" {
+			payload["channel"] = recipient.Target
+		}
+		if s.cfg.Username != "" {
+			payload["username"] = s.cfg.Username
+		}
+		if s.cfg.IconURL != "" {
+			payload["icon_url"] = s.cfg.IconURL
+		}
+		return s.post(ctx, s.cfg.WebhookURL, "", payload)
+	}
+
+	payload := map[string]interface{}{
+		"channel_id": recipient.Target,
+		"message":    "",
+		"props":      map[string]interface{}{"attachments": attachments},
+	}
+	return s.post(ctx, s.cfg.URL+"/api/v4/posts", s.cfg.Token, payload)
+}
+
+func buttonLinks(buttons []domain.Button) string {
+	links := make([]string, len(buttons))
+	for i, button := range buttons {
+		links[i] = fmt.Sprintf("[%s](%s)", button.Text, button.URL)
+	}
+	return strings.Join(links, " · ")
+}
+
+func (s *Sender) post(ctx context.Context, endpoint, token string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mattermost API error: status %d, response: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
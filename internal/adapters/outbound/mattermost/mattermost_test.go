@@ -0,0 +1,140 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// post is a request the stub server received
+type post struct {
+	path    string
+	auth    string
+	payload map[string]interface{}
+}
+
+func newStubServer(t *testing.T, status int, posts *[]post) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		*posts = append(*posts, post{path: r.URL.Path, auth: r.Header.Get("Authorization"), payload: payload})
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":"post"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var notification = domain.Notification{
+	Body:     "*main* is _red_",
+	Severity: domain.SeverityCritical,
+	Buttons:  []domain.Button{{Text: "Logs", URL: "https://ci.example.com/1"}},
+}
+
+func firstAttachment(t *testing.T, attachments interface{}) map[string]interface{} {
+	t.Helper()
+	list, ok := attachments.([]interface{})
+	if !ok || len(list) != 1 {
+		t.Fatalf("attachments = %v, want one", attachments)
+	}
+	return list[0].(map[string]interface{})
+}
+
+func TestSendWebhook(t *testing.T) {
+	var posts []post
+	server := newStubServer(t, http.StatusOK, &posts)
+	sender := NewSender(Settings{WebhookURL: server.URL + "/hooks/xxx", Username: "hookrelay"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "support", Target: "support"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 || posts[0].path != "/hooks/xxx" || posts[0].auth != "" {
+		t.Fatalf("posts = %+v, want one to the webhook without a token", posts)
+	}
+	payload := posts[0].payload
+	if payload["channel"] != "support" || payload["username"] != "hookrelay" {
+		t.Errorf("payload = %v", payload)
+	}
+	attachment := firstAttachment(t, payload["attachments"])
+	if attachment["color"] != common.SeverityColor(domain.SeverityCritical) {
+		t.Errorf("color = %v", attachment["color"])
+	}
+	if attachment["text"] != "**main** is _red_\n\n[Logs](https://ci.example.com/1)" {
+		t.Errorf("text = %q", attachment["text"])
+	}
+	if attachment["fallback"] != "main is red" {
+		t.Errorf("fallback = %q", attachment["fallback"])
+	}
+}
+
+func TestSendREST(t *testing.T) {
+	var posts []post
+	server := newStubServer(t, http.StatusCreated, &posts)
+	sender := NewSender(Settings{URL: server.URL + "/", Token: "bot-token"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "support", Target: "channel-id"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 || posts[0].path != "/api/v4/posts" || posts[0].auth != "Bearer bot-token" {
+		t.Fatalf("posts = %+v, want one to the posts API with the token", posts)
+	}
+	payload := posts[0].payload
+	if payload["channel_id"] != "channel-id" {
+		t.Errorf("channel_id = %v", payload["channel_id"])
+	}
+	props, _ := payload["props"].(map[string]interface{})
+	firstAttachment(t, props["attachments"])
+}
+
+func TestSendReportsAPIErrors(t *testing.T) {
+	var posts []post
+	server := newStubServer(t, http.StatusForbidden, &posts)
+	sender := NewSender(Settings{URL: server.URL, Token: "bot-token"})
+
+	err := sender.Send(context.Background(), config.Recipient{Name: "support", Target: "channel-id"}, notification)
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("Send() = %v, want the status of the server", err)
+	}
+}
+
+func TestValidateRecipient(t *testing.T) {
+	webhook := Settings{WebhookURL: "https://mattermost.example.com/hooks/xxx"}
+	rest := Settings{URL: "https://mattermost.example.com", Token: "bot-token"}
+	tests := []struct {
+		name      string
+		settings  Settings
+		recipient config.Recipient
+		wantErr   string
+	}{
+		{name: "webhook default channel", settings: webhook, recipient: config.Recipient{Name: "support"}},
+		{name: "webhook channel override", settings: webhook, recipient: config.Recipient{Name: "support", Target: "support"}},
+		{name: "rest channel", settings: rest, recipient: config.Recipient{Name: "support", Target: "channel-id"}},
+		{name: "rest without channel", settings: rest, recipient: config.Recipient{Name: "support"}, wantErr: "empty 'target' value"},
+		{
+			name:      "options",
+			settings:  rest,
+			recipient: config.Recipient{Name: "support", Target: "channel-id", Options: map[string]interface{}{"props": nil}},
+			wantErr:   "unknown options",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRecipient(tt.settings, tt.recipient)
+			if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+				t.Errorf("ValidateRecipient() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package mattermost
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "mattermost"
+
+// Settings configure an incoming webhook or, without one, the REST API with a bot token
+type Settings struct {
+	WebhookURL string `mapstructure:"webhook_url"` // incoming webhook; a recipient target overrides its channel
+	URL        string `mapstructure:"url"`         // server URL for the REST API, the recipient target is a channel id
+	Token      string `mapstructure:"token"`       // bot access token for the REST API
+	Username   string `mapstructure:"username"`    // display name of webhook posts
+	IconURL    string `mapstructure:"icon_url"`
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate:          Validate,
+		ValidateRecipient: ValidateRecipient,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
@@ -0,0 +1,136 @@
+package natsbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	ConfirmNone      = "none"
+	ConfirmFlush     = "flush"
+	ConfirmJetStream = "jetstream"
+
+	defaultTimeout = 10 * time.Second
+)
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+	confirm string
+	timeout time.Duration
+}
+
+// Validate checks the settings without connecting to the server
+func Validate(cfg Settings) error {
+	if cfg.URL == "" {
+		return fmt.Errorf("empty 'url' value")
+	}
+	switch cfg.Confirm {
+	case "", ConfirmNone, ConfirmFlush, ConfirmJetStream:
+		return nil
+	default:
+		return fmt.Errorf("unknown confirm mode '%s'", cfg.Confirm)
+	}
+}
+
+// NewSender connects in the background: notifications published while the server is unreachable
+// are buffered until the connection is up, and fail if a confirmation is required.
+func NewSender(cfg Settings) (*Sender, error) {
+	confirm := cfg.Confirm
+	if confirm == "" {
+		confirm = ConfirmFlush
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	opts := []nats.Option{
+		nats.Name("hookrelay"),
+		nats.Timeout(timeout),
+		nats.RetryOnFailedConnect(true),
+	}
+	if cfg.MaxReconnects != 0 {
+		opts = append(opts, nats.MaxReconnects(cfg.MaxReconnects))
+	}
+	if cfg.ReconnectWait > 0 {
+		opts = append(opts, nats.ReconnectWait(cfg.ReconnectWait))
+	}
+	if cfg.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	}
+
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	return &Sender{
+		conn:    conn,
+		js:      js,
+		subject: cfg.Subject,
+		confirm: confirm,
+		timeout: timeout,
+	}, nil
+}
+
+// Send publishes the message to the subject in recipient.Target, or in the settings
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	subject := common.BusTopic(s.subject, recipient, notification)
+	if subject == "" {
+		return fmt.Errorf("recipient '%s' has no subject", recipient.Name)
+	}
+
+	message := common.NewBusMessage(recipient, notification)
+	data, err := message.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if s.confirm == ConfirmJetStream {
+		msg := nats.NewMsg(subject)
+		msg.Data = data
+		msg.Header.Set("Content-Type", "application/json")
+		// the message id lets the stream drop duplicates of a retried publish
+		if _, err := s.js.PublishMsg(ctx, msg, jetstream.WithMsgID(message.ID)); err != nil {
+			return fmt.Errorf("failed to publish to stream of '%s': %w", subject, err)
+		}
+		return nil
+	}
+
+	// no headers here: they cannot be buffered before the first connection tells that the server supports them
+	if err := s.conn.Publish(subject, data); err != nil {
+		return fmt.Errorf("failed to publish to '%s': %w", subject, err)
+	}
+	if s.confirm == ConfirmNone {
+		return nil
+	}
+	if err := s.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to confirm publish to '%s': %w", subject, err)
+	}
+	return nil
+}
+
+// Close publishes the buffered messages and closes the connection
+func (s *Sender) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,143 @@
+package natsbus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+var notification = domain.Notification{Title: "Push", Body: "*main* updated", Webhook: "ci", Event: "push"}
+
+// runServer starts an in-process NATS server with JetStream on a random port
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server is not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newTestSender(t *testing.T, srv *server.Server, settings Settings) *Sender {
+	t.Helper()
+	settings.URL = srv.ClientURL()
+	if err := Validate(settings); err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sender.Close() })
+	return sender
+}
+
+func subscribe(t *testing.T, srv *server.Server, subject string) *nats.Subscription {
+	t.Helper()
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	sub, err := conn.SubscribeSync(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func TestSendPublishes(t *testing.T) {
+	for _, confirm := range []string{ConfirmNone, ConfirmFlush} {
+		t.Run(confirm, func(t *testing.T) {
+			srv := runServer(t)
+			sub := subscribe(t, srv, "hookrelay.>")
+			sender := newTestSender(t, srv, Settings{Subject: "hookrelay.{webhook}.{event}", Confirm: confirm})
+
+			if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := sub.NextMsg(5 * time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Subject != "hookrelay.ci.push" {
+				t.Errorf("subject = %s, want hookrelay.ci.push", msg.Subject)
+			}
+			var message common.BusMessage
+			if err := json.Unmarshal(msg.Data, &message); err != nil {
+				t.Fatal(err)
+			}
+			if message.Recipient != "archive" || message.Event.Webhook != "ci" || message.Notification.Text != "main updated" {
+				t.Errorf("message = %+v", message)
+			}
+		})
+	}
+}
+
+func TestSendToRecipientSubject(t *testing.T) {
+	srv := runServer(t)
+	sub := subscribe(t, srv, "audit.push")
+	sender := newTestSender(t, srv, Settings{Subject: "hookrelay.{webhook}"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "audit", Target: "audit.{event}"}, notification); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextMsg(5 * time.Second); err != nil {
+		t.Errorf("no message on the recipient subject: %v", err)
+	}
+}
+
+func TestSendToJetStream(t *testing.T) {
+	srv := runServer(t)
+	sender := newTestSender(t, srv, Settings{Subject: "hookrelay.{webhook}", Confirm: ConfirmJetStream, Timeout: 2 * time.Second})
+
+	// without a stream for the subject nothing confirms the message
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err == nil {
+		t.Error("Send() without a stream succeeded")
+	}
+
+	ctx := context.Background()
+	stream, err := sender.js.CreateStream(ctx, jetstream.StreamConfig{Name: "HOOKS", Subjects: []string{"hookrelay.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(ctx, config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := stream.GetLastMsgForSubject(ctx, "hookrelay.ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message common.BusMessage
+	if err := json.Unmarshal(msg.Data, &message); err != nil {
+		t.Fatal(err)
+	}
+	if id := msg.Header.Get(jetstream.MsgIDHeader); id != message.ID {
+		t.Errorf("%s = %q, want the message id %q", jetstream.MsgIDHeader, id, message.ID)
+	}
+}
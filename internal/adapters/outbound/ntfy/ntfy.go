@@ -0,0 +1,141 @@
+package ntfy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	DefaultServerURL = "https://ntfy.sh"
+
+	maxActions = 3
+)
+
+// priorities maps severities to ntfy priorities, 1 (min) to 5 (max)
+var priorities = map[domain.Severity]int{
+	domain.SeverityInfo:     3,
+	domain.SeverityWarning:  4,
+	domain.SeverityCritical: 5,
+}
+
+// severityTags are emoji shortcodes that ntfy shows in front of the title
+var severityTags = map[domain.Severity]string{
+	domain.SeverityWarning:  "warning",
+	domain.SeverityCritical: "rotating_light",
+}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client    *http.Client
+	serverURL string
+	token     string
+}
+
+func Validate(cfg Settings) error {
+	if cfg.ServerURL == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.ServerURL)
+	if err != nil {
+		return fmt.Errorf("invalid 'server_url' value: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid 'server_url' value '%s', expected an http or https URL", cfg.ServerURL)
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	serverURL := strings.TrimRight(cfg.ServerURL, "/")
+	if serverURL == "" {
+		serverURL = DefaultServerURL
+	}
+	return &Sender{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		serverURL: serverURL,
+		token:     cfg.Token,
+	}
+}
+
+// message is the JSON form of a ntfy publish request
+type message struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click,omitempty"`
+	Actions  []action `json:"actions,omitempty"`
+}
+
+type action struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+}
+
+// Send publishes the notification to the topic in recipient.Target.
+// The first button becomes the click URL and up to three buttons become view actions.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	msg := message{
+		Topic:    recipient.Target,
+		Title:    common.PlainTitle(notification),
+		Message:  common.MarkdownToText(notification.Body),
+		Priority: priorities[notification.Severity],
+	}
+	if tag, ok := severityTags[notification.Severity]; ok {
+		msg.Tags = append(msg.Tags, tag)
+	}
+	for _, tag := range []string{notification.Webhook, notification.Event} {
+		if tag != "" {
+			msg.Tags = append(msg.Tags, tag)
+		}
+	}
+	for i, button := range notification.Buttons {
+		if i == 0 {
+			msg.Click = button.URL
+		}
+		if i < maxActions {
+			msg.Actions = append(msg.Actions, action{Action: "view", Label: button.Text, URL: button.URL})
+		}
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.serverURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ntfy API error: status %d, response: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package ntfy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+func TestSendPublishesJSON(t *testing.T) {
+	var got message
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"id":"abc"}`))
+	}))
+	defer server.Close()
+
+	sender := NewSender(Settings{ServerURL: server.URL + "/", Token: "tk_secret"})
+	err := sender.Send(context.Background(), config.Recipient{Name: "phone", Target: "alerts"}, domain.Notification{
+		Title:    "Deploy failed",
+		Body:     "*prod* is down",
+		Severity: domain.SeverityCritical,
+		Webhook:  "ci",
+		Event:    "deployment_status",
+		Buttons: []domain.Button{
+			{Text: "Run", URL: "https://ci.example.com/run"},
+			{Text: "Diff", URL: "https://git.example.com/diff"},
+			{Text: "Logs", URL: "https://ci.example.com/logs"},
+			{Text: "Docs", URL: "https://docs.example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer tk_secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	want := message{
+		Topic:    "alerts",
+		Title:    "Deploy failed",
+		Message:  "prod is down",
+		Priority: 5,
+		Tags:     []string{"rotating_light", "ci", "deployment_status"},
+		Click:    "https://ci.example.com/run",
+		Actions: []action{
+			{Action: "view", Label: "Run", URL: "https://ci.example.com/run"},
+			{Action: "view", Label: "Diff", URL: "https://git.example.com/diff"},
+			{Action: "view", Label: "Logs", URL: "https://ci.example.com/logs"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("message = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSendReportsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("token sent without one configured")
+		}
+		http.Error(w, `{"code":40301,"error":"forbidden"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := NewSender(Settings{ServerURL: server.URL}).Send(context.Background(), config.Recipient{Target: "alerts"}, domain.Notification{Body: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "status 403") || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Send() = %v, want the status and response of the server", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for serverURL, valid := range map[string]bool{
+		"":                         true,
+		"https://ntfy.example.com": true,
+		"ntfy.example.com":         false,
+		"ftp://ntfy.example.com":   false,
+	} {
+		if err := Validate(Settings{ServerURL: serverURL}); (err == nil) != valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", serverURL, err, valid)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package ntfy
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "ntfy"
+
+type Settings struct {
+	ServerURL string `mapstructure:"server_url"` // 'https://ntfy.sh' by default; the recipient target is the topic
+	Token     string `mapstructure:"token"`      // access token for protected topics
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
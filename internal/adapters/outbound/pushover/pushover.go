@@ -0,0 +1,118 @@
+package pushover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	DefaultAPIURL = "https://api.pushover.net"
+
+	maxTitleLength    = 250
+	maxMessageLength  = 1024
+	maxURLTitleLength = 100
+
+	priorityEmergency = 2
+	defaultRetry      = time.Minute
+	defaultExpire     = time.Hour
+)
+
+// priorities maps severities to Pushover priorities. Emergency notifications repeat until acknowledged.
+var priorities = map[domain.Severity]int{
+	domain.SeverityInfo:     0,
+	domain.SeverityWarning:  1,
+	domain.SeverityCritical: priorityEmergency,
+}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client *http.Client
+	apiURL string
+	token  string
+	retry  time.Duration
+	expire time.Duration
+}
+
+func Validate(cfg Settings) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("empty 'token' value")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	retry := cfg.Retry
+	if retry <= 0 {
+		retry = defaultRetry
+	}
+	expire := cfg.Expire
+	if expire <= 0 {
+		expire = defaultExpire
+	}
+	return &Sender{
+		client: &http.Client{Timeout: 10 * time.Second},
+		apiURL: apiURL,
+		token:  cfg.Token,
+		retry:  retry,
+		expire: expire,
+	}
+}
+
+// Send pushes the notification to the user or group key in recipient.Target.
+// The first button becomes the supplementary URL of the message.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	priority := priorities[notification.Severity]
+	form := url.Values{
+		"token":    {s.token},
+		"user":     {recipient.Target},
+		"title":    {common.Truncate(common.PlainTitle(notification), maxTitleLength)},
+		"message":  {common.Truncate(common.MarkdownToText(notification.Body), maxMessageLength)},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if priority == priorityEmergency {
+		form.Set("retry", strconv.Itoa(int(s.retry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(s.expire.Seconds())))
+	}
+	if len(notification.Buttons) > 0 {
+		form.Set("url", notification.Buttons[0].URL)
+		form.Set("url_title", common.Truncate(notification.Buttons[0].Text, maxURLTitleLength))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	var result struct {
+		Status int `json:"status"`
+	}
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(respBody, &result) != nil || result.Status != 1 {
+		return fmt.Errorf("pushover API error: status %d, response: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
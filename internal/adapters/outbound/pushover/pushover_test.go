@@ -0,0 +1,104 @@
+package pushover
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// newTestServer answers with status and body and records the form of the last request
+func newTestServer(t *testing.T, status int, body string, form *url.Values) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/messages.json" {
+			t.Errorf("path = %s, want /1/messages.json", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		*form = r.PostForm
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendPushesMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		severity domain.Severity
+		want     map[string]string // expected form values, empty for absent
+	}{
+		{
+			name:     "info",
+			severity: domain.SeverityInfo,
+			want:     map[string]string{"priority": "0", "retry": "", "expire": ""},
+		},
+		{
+			name:     "critical repeats until acknowledged",
+			severity: domain.SeverityCritical,
+			want:     map[string]string{"priority": "2", "retry": "30", "expire": "600"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			server := newTestServer(t, http.StatusOK, `{"status":1,"request":"r"}`, &form)
+
+			sender := NewSender(Settings{APIURL: server.URL + "/", Token: "app", Retry: 30 * time.Second, Expire: 10 * time.Minute})
+			err := sender.Send(context.Background(), config.Recipient{Target: "user-key"}, domain.Notification{
+				Title:    "Alert",
+				Body:     strings.Repeat("x", 2000),
+				Severity: tt.severity,
+				Buttons:  []domain.Button{{Text: "Open", URL: "https://example.com"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]string{"token": "app", "user": "user-key", "title": "Alert", "url": "https://example.com", "url_title": "Open"}
+			for key, value := range tt.want {
+				want[key] = value
+			}
+			for key, value := range want {
+				if got := form.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+			if n := len([]rune(form.Get("message"))); n > maxMessageLength {
+				t.Errorf("message has %d characters, want at most %d", n, maxMessageLength)
+			}
+		})
+	}
+}
+
+func TestSendReportsAPIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"invalid user", http.StatusBadRequest, `{"user":"invalid","errors":["user identifier is invalid"],"status":0}`},
+		{"status 0 with 200", http.StatusOK, `{"status":0}`},
+		{"not json", http.StatusOK, `<html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			server := newTestServer(t, tt.status, tt.body, &form)
+
+			err := NewSender(Settings{APIURL: server.URL, Token: "app"}).Send(context.Background(), config.Recipient{Target: "bad"}, domain.Notification{Body: "hi"})
+			if err == nil || !strings.Contains(err.Error(), tt.body) {
+				t.Errorf("Send() = %v, want an error with the response", err)
+			}
+		})
+	}
+}
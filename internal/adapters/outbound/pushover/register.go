@@ -0,0 +1,27 @@
+package pushover
+
+import (
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "pushover"
+
+type Settings struct {
+	APIURL string        `mapstructure:"api_url"` // 'https://api.pushover.net' by default; the recipient target is a user or group key
+	Token  string        `mapstructure:"token"`   // application token
+	Retry  time.Duration `mapstructure:"retry"`   // how often critical notifications repeat until acknowledged, '60s' by default
+	Expire time.Duration `mapstructure:"expire"`  // how long critical notifications repeat, '1h' by default
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
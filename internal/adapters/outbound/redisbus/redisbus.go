@@ -0,0 +1,121 @@
+package redisbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client          *redis.Client
+	stream          string
+	maxLen          int64
+	confirmReplicas int
+	timeout         time.Duration
+}
+
+// Validate checks the settings without connecting to the server
+func Validate(cfg Settings) error {
+	if cfg.URL == "" {
+		return fmt.Errorf("empty 'url' value")
+	}
+	if _, err := redis.ParseURL(cfg.URL); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if cfg.ConfirmReplicas < 0 {
+		return fmt.Errorf("negative 'confirm_replicas' value")
+	}
+	return nil
+}
+
+// NewSender does not connect: the client dials on the first notification and redials after a
+// failure, retrying the command as configured.
+func NewSender(cfg Settings) (*Sender, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	opts.DialTimeout = timeout
+	opts.WriteTimeout = timeout
+	opts.ReadTimeout = timeout
+	if cfg.ConfirmReplicas > 0 {
+		// WAIT blocks for up to the timeout before it replies
+		opts.ReadTimeout = 2 * timeout
+	}
+	if cfg.MaxReconnects != 0 {
+		opts.MaxRetries = cfg.MaxReconnects
+	}
+	if cfg.ReconnectWait > 0 {
+		opts.MinRetryBackoff = cfg.ReconnectWait
+	}
+
+	return &Sender{
+		client:          redis.NewClient(opts),
+		stream:          cfg.Stream,
+		maxLen:          cfg.MaxLen,
+		confirmReplicas: cfg.ConfirmReplicas,
+		timeout:         timeout,
+	}, nil
+}
+
+// Send appends the message to the stream in recipient.Target, or in the settings, as the
+// 'message' field of an entry with an id assigned by the server
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	stream := common.BusTopic(s.stream, recipient, notification)
+	if stream == "" {
+		return fmt.Errorf("recipient '%s' has no stream", recipient.Name)
+	}
+
+	message := common.NewBusMessage(recipient, notification)
+	data, err := message.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	// WAIT applies to the writes of its own connection, a pipeline keeps both commands on one
+	pipe := s.client.Pipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: []string{"id", message.ID, "message", string(data)},
+	})
+	var wait *redis.Cmd
+	if s.confirmReplicas > 0 {
+		wait = pipe.Do(ctx, "wait", s.confirmReplicas, s.timeout.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to add entry to stream '%s': %w", stream, err)
+	}
+
+	if wait == nil {
+		return nil
+	}
+	replicas, err := wait.Int64()
+	if err != nil {
+		return fmt.Errorf("failed to wait for replicas: %w", err)
+	}
+	if replicas < int64(s.confirmReplicas) {
+		return fmt.Errorf("entry %s of stream '%s' reached %d of %d replicas", add.Val(), stream, replicas, s.confirmReplicas)
+	}
+	return nil
+}
+
+func (s *Sender) Close() error {
+	return s.client.Close()
+}
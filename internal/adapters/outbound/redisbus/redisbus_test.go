@@ -0,0 +1,121 @@
+package redisbus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+var notification = domain.Notification{Title: "Push", Body: "*main* updated", Webhook: "ci", Event: "push", EntityKey: "ci:main"}
+
+func newTestSender(t *testing.T, server *miniredis.Miniredis, settings Settings) *Sender {
+	t.Helper()
+	settings.URL = "redis://" + server.Addr()
+	if err := Validate(settings); err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sender.Close() })
+	return sender
+}
+
+// entries decodes the messages of a stream
+func entries(t *testing.T, server *miniredis.Miniredis, stream string) []common.BusMessage {
+	t.Helper()
+	stored, err := server.Stream(stream)
+	if err != nil {
+		t.Fatalf("stream %s: %v", stream, err)
+	}
+	var messages []common.BusMessage
+	for _, entry := range stored {
+		values := make(map[string]string)
+		for i := 0; i+1 < len(entry.Values); i += 2 {
+			values[entry.Values[i]] = entry.Values[i+1]
+		}
+		var message common.BusMessage
+		if err := json.Unmarshal([]byte(values["message"]), &message); err != nil {
+			t.Fatalf("entry %s: %v", entry.ID, err)
+		}
+		if values["id"] != message.ID {
+			t.Errorf("entry id field = %q, want the message id %q", values["id"], message.ID)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestSendAddsStreamEntry(t *testing.T) {
+	server := miniredis.RunT(t)
+	sender := newTestSender(t, server, Settings{Stream: "hookrelay:{webhook}"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := entries(t, server, "hookrelay:ci")
+	if len(messages) != 1 {
+		t.Fatalf("%d entries, want 1", len(messages))
+	}
+	message := messages[0]
+	if message.Recipient != "archive" || message.Event.Name != "push" || message.Event.Entity != "ci:main" {
+		t.Errorf("message = %+v", message)
+	}
+	if message.Notification.Body != "*main* updated" || message.Notification.Text != "main updated" {
+		t.Errorf("notification = %+v", message.Notification)
+	}
+}
+
+func TestSendToRecipientStream(t *testing.T) {
+	server := miniredis.RunT(t)
+	sender := newTestSender(t, server, Settings{Stream: "hookrelay:{webhook}", MaxLen: 2})
+
+	recipient := config.Recipient{Name: "audit", Target: "audit:{event}"}
+	for i := 0; i < 3; i++ {
+		if err := sender.Send(context.Background(), recipient, notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(entries(t, server, "audit:push")); n < 1 || n > 3 {
+		t.Errorf("%d entries in the recipient stream", n)
+	}
+	if server.Exists("hookrelay:ci") {
+		t.Error("the settings stream is used despite the recipient target")
+	}
+}
+
+func TestSendReconnectsAfterRestart(t *testing.T) {
+	server := miniredis.RunT(t)
+	sender := newTestSender(t, server, Settings{Stream: "events"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err != nil {
+		t.Fatalf("Send() after a restart = %v", err)
+	}
+	if n := len(entries(t, server, "events")); n != 2 {
+		t.Errorf("%d entries, want 2", n)
+	}
+}
+
+func TestSendWithoutStream(t *testing.T) {
+	server := miniredis.RunT(t)
+	sender := newTestSender(t, server, Settings{})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "archive"}, notification); err == nil {
+		t.Error("Send() without a stream succeeded")
+	}
+}
@@ -0,0 +1,29 @@
+package rocketchat
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "rocketchat"
+
+// Settings configure an incoming webhook or, without one, the REST API with a bot token
+type Settings struct {
+	WebhookURL string `mapstructure:"webhook_url"` // incoming webhook; a recipient target such as '#ops' or '@alice' overrides its channel
+	URL        string `mapstructure:"url"`         // server URL for the REST API, the recipient target is a channel or user
+	UserID     string `mapstructure:"user_id"`     // bot user id for the REST API
+	Token      string `mapstructure:"token"`       // bot personal access token for the REST API
+	Alias      string `mapstructure:"alias"`       // display name of the messages
+	Avatar     string `mapstructure:"avatar"`      // avatar URL of the messages
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate:          Validate,
+		ValidateRecipient: ValidateRecipient,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings), nil
+		},
+	})
+}
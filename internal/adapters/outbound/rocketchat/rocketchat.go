@@ -0,0 +1,132 @@
+package rocketchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// dialect is the Slack-like Markdown Rocket.Chat renders, without backslash escapes
+var dialect = common.MarkdownDialect{Bold: "*", Italic: "_"}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	client *http.Client
+	cfg    Settings
+}
+
+func Validate(cfg Settings) error {
+	if cfg.WebhookURL == "" && (cfg.URL == "" || cfg.UserID == "" || cfg.Token == "") {
+		return fmt.Errorf("either 'webhook_url' or 'url', 'user_id' and 'token' must be set")
+	}
+	return nil
+}
+
+// ValidateRecipient requires a channel or user for the REST API, webhook posts go to the default channel without one
+func ValidateRecipient(cfg Settings, recipient config.Recipient) error {
+	if cfg.WebhookURL == "" && recipient.Target == "" {
+		return fmt.Errorf("empty 'target' value, expected a channel or user")
+	}
+	if len(recipient.Options) > 0 {
+		return fmt.Errorf("unknown options, rocketchat recipients have none")
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) *Sender {
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &Sender{
+		client: &http.Client{Timeout: 10 * time.Second},
+		cfg:    cfg,
+	}
+}
+
+// attachment is a message attachment, which carries the color of the severity
+type attachment struct {
+	Color string `json:"color"`
+	Text  string `json:"text"`
+}
+
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	text := common.MarkdownToDialect(notification.Body, dialect)
+	if links := buttonLinks(notification.Buttons); links != "" {
+		text += "\n\n" + links
+	}
+
+	payload := map[string]interface{}{
+		"attachments": []attachment{{
+			Color: common.SeverityColor(notification.Severity),
+			Text:  text,
+		}},
+	}
+	if recipient.Target != "" {
+		payload["channel"] = recipient.Target
+	}
+	if s.cfg.Alias != "" {
+		payload["alias"] = s.cfg.Alias
+	}
+	if s.cfg.Avatar != "" {
+		payload["avatar"] = s.cfg.Avatar
+	}
+
+	if s.cfg.WebhookURL != "" {
+		return s.post(ctx, s.cfg.WebhookURL, false, payload)
+	}
+	if recipient.Target == "" {
+		return fmt.Errorf("recipient '%s' has no channel", recipient.Name)
+	}
+	return s.post(ctx, s.cfg.URL+"/api/v1/chat.postMessage", true, payload)
+}
+
+func buttonLinks(buttons []domain.Button) string {
+	links := make([]string, len(buttons))
+	for i, button := range buttons {
+		links[i] = fmt.Sprintf("[%s](%s)", button.Text, button.URL)
+	}
+	return strings.Join(links, " · ")
+}
+
+func (s *Sender) post(ctx context.Context, endpoint string, authenticate bool, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authenticate {
+		req.Header.Set("X-User-Id", s.cfg.UserID)
+		req.Header.Set("X-Auth-Token", s.cfg.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	// Rocket.Chat may answer 200 with success false
+	if resp.StatusCode != http.StatusOK || (json.Unmarshal(respBody, &result) == nil && !result.Success && result.Error != "") {
+		return fmt.Errorf("rocket.chat API error: status %d, response: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
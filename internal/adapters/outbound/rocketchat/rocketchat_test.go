@@ -0,0 +1,145 @@
+package rocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// post is a request the stub server received
+type post struct {
+	path          string
+	userID, token string
+	payload       map[string]interface{}
+}
+
+func newStubServer(t *testing.T, status int, response string, posts *[]post) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		*posts = append(*posts, post{
+			path:    r.URL.Path,
+			userID:  r.Header.Get("X-User-Id"),
+			token:   r.Header.Get("X-Auth-Token"),
+			payload: payload,
+		})
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var notification = domain.Notification{
+	Body:     "*main* is _red_",
+	Severity: domain.SeverityWarning,
+	Buttons:  []domain.Button{{Text: "Logs", URL: "https://ci.example.com/1"}},
+}
+
+func TestSendWebhook(t *testing.T) {
+	var posts []post
+	server := newStubServer(t, http.StatusOK, `{"success":true}`, &posts)
+	sender := NewSender(Settings{WebhookURL: server.URL + "/hooks/xxx", Alias: "hookrelay"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "ops", Target: "#ops"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 || posts[0].path != "/hooks/xxx" || posts[0].token != "" {
+		t.Fatalf("posts = %+v, want one to the webhook without a token", posts)
+	}
+	payload := posts[0].payload
+	if payload["channel"] != "#ops" || payload["alias"] != "hookrelay" {
+		t.Errorf("payload = %v", payload)
+	}
+	attachments, _ := payload["attachments"].([]interface{})
+	if len(attachments) != 1 {
+		t.Fatalf("attachments = %v, want one", payload["attachments"])
+	}
+	attachment := attachments[0].(map[string]interface{})
+	if attachment["color"] != common.SeverityColor(domain.SeverityWarning) {
+		t.Errorf("color = %v", attachment["color"])
+	}
+	if attachment["text"] != "*main* is _red_\n\n[Logs](https://ci.example.com/1)" {
+		t.Errorf("text = %q", attachment["text"])
+	}
+}
+
+func TestSendREST(t *testing.T) {
+	var posts []post
+	server := newStubServer(t, http.StatusOK, `{"success":true}`, &posts)
+	sender := NewSender(Settings{URL: server.URL + "/", UserID: "bot", Token: "bot-token"})
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "alice", Target: "@alice"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 || posts[0].path != "/api/v1/chat.postMessage" || posts[0].userID != "bot" || posts[0].token != "bot-token" {
+		t.Fatalf("posts = %+v, want one to chat.postMessage with the credentials", posts)
+	}
+	if posts[0].payload["channel"] != "@alice" {
+		t.Errorf("channel = %v", posts[0].payload["channel"])
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{name: "error status", status: http.StatusUnauthorized, response: `{"status":"error","message":"unauthorized"}`},
+		{name: "success false", status: http.StatusOK, response: `{"success":false,"error":"error-invalid-channel"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts []post
+			server := newStubServer(t, tt.status, tt.response, &posts)
+			sender := NewSender(Settings{URL: server.URL, UserID: "bot", Token: "bot-token"})
+
+			err := sender.Send(context.Background(), config.Recipient{Name: "ops", Target: "#ops"}, notification)
+			if err == nil || !strings.Contains(err.Error(), tt.response) {
+				t.Errorf("Send() = %v, want the response of the server", err)
+			}
+		})
+	}
+}
+
+func TestValidateRecipient(t *testing.T) {
+	webhook := Settings{WebhookURL: "https://chat.example.com/hooks/xxx"}
+	rest := Settings{URL: "https://chat.example.com", UserID: "bot", Token: "bot-token"}
+	tests := []struct {
+		name      string
+		settings  Settings
+		recipient config.Recipient
+		wantErr   string
+	}{
+		{name: "webhook default channel", settings: webhook, recipient: config.Recipient{Name: "ops"}},
+		{name: "rest channel", settings: rest, recipient: config.Recipient{Name: "ops", Target: "#ops"}},
+		{name: "rest without channel", settings: rest, recipient: config.Recipient{Name: "ops"}, wantErr: "empty 'target' value"},
+		{
+			name:      "options",
+			settings:  webhook,
+			recipient: config.Recipient{Name: "ops", Options: map[string]interface{}{"emoji": ":bell:"}},
+			wantErr:   "unknown options",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRecipient(tt.settings, tt.recipient)
+			if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+				t.Errorf("ValidateRecipient() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
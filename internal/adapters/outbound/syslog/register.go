@@ -0,0 +1,28 @@
+package syslog
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "syslog"
+
+type Settings struct {
+	Network            string `mapstructure:"network"`              // 'udp' (default), 'tcp' or 'tls'
+	Address            string `mapstructure:"address"`              // 'host:port' of the collector
+	Facility           string `mapstructure:"facility"`             // e.g. 'daemon' or 'local3', 'local0' by default
+	AppName            string `mapstructure:"app_name"`             // 'hookrelay' by default
+	Hostname           string `mapstructure:"hostname"`             // the host name of the machine by default
+	CAFile             string `mapstructure:"ca_file"`              // PEM bundle trusted in addition to the system roots for 'tls'
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // for collectors with self-signed certificates
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		New: func(_ config.NotifierName, settings Settings, _ registry.Env) (ports.Notifier, error) {
+			return NewSender(settings)
+		},
+	})
+}
@@ -0,0 +1,270 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+
+	defaultAppName  = "hookrelay"
+	defaultFacility = "local0"
+	dialTimeout     = 10 * time.Second
+
+	// sdID names the structured data element, 32473 is the enterprise number reserved for examples
+	sdID     = "hookrelay@32473"
+	nilValue = "-"
+	utf8BOM  = "\ufeff" // marks MSG as UTF-8
+)
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// severities maps notification severities to syslog severities
+var severities = map[domain.Severity]int{
+	domain.SeverityInfo:     6, // informational
+	domain.SeverityWarning:  4, // warning
+	domain.SeverityCritical: 2, // critical
+}
+
+var _ ports.Notifier = (*Sender)(nil)
+
+type Sender struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	facility  int
+	appName   string
+	hostname  string
+	procID    string
+
+	mu   sync.Mutex
+	conn net.Conn // kept open between messages, reopened after a failure
+}
+
+// Validate checks the settings without connecting to the collector
+func Validate(cfg Settings) error {
+	if cfg.Address == "" {
+		return fmt.Errorf("empty 'address' value")
+	}
+	switch cfg.Network {
+	case "", NetworkUDP, NetworkTCP, NetworkTLS:
+	default:
+		return fmt.Errorf("unknown network '%s'", cfg.Network)
+	}
+	if _, ok := facilities[cfg.Facility]; cfg.Facility != "" && !ok {
+		return fmt.Errorf("unknown facility '%s'", cfg.Facility)
+	}
+	return nil
+}
+
+func NewSender(cfg Settings) (*Sender, error) {
+	network := cfg.Network
+	if network == "" {
+		network = NetworkUDP
+	}
+	var tlsConfig *tls.Config
+	if network == NetworkTLS {
+		var err error
+		if tlsConfig, err = newTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	facilityName := cfg.Facility
+	if facilityName == "" {
+		facilityName = defaultFacility
+	}
+	facility := facilities[facilityName]
+
+	appName := cfg.AppName
+	if appName == "" {
+		appName = defaultAppName
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	return &Sender{
+		network:   network,
+		address:   cfg.Address,
+		tlsConfig: tlsConfig,
+		facility:  facility,
+		appName:   headerField(appName, 48),
+		hostname:  headerField(hostname, 255),
+		procID:    strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+func newTLSConfig(cfg Settings) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%s': %w", cfg.Address, err)
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file '%s'", cfg.CAFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// Send writes the notification as one RFC 5424 message. The recipient target is not used.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	msg := s.format(notification, time.Now())
+	if s.network != NetworkUDP {
+		// octet counting framing of RFC 6587 and RFC 5425
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		reused := s.conn != nil
+		if !reused {
+			if err := s.connect(ctx); err != nil {
+				return err
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			s.conn.SetWriteDeadline(deadline)
+		} else {
+			s.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+		}
+		_, err := s.conn.Write([]byte(msg))
+		if err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+		// the collector may have closed an idle stream connection
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return fmt.Errorf("failed to write syslog message: %w", err)
+		}
+	}
+}
+
+func (s *Sender) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if s.network == NetworkTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", s.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, s.network, s.address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// format renders '<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG'
+func (s *Sender) format(notification domain.Notification, now time.Time) string {
+	severity, ok := severities[notification.Severity]
+	if !ok {
+		severity = severities[domain.SeverityInfo]
+	}
+
+	msgID := nilValue
+	if notification.Event != "" {
+		msgID = headerField(notification.Event, 32)
+	}
+
+	params := []string{
+		sdParam("webhook", notification.Webhook),
+		sdParam("event", notification.Event),
+		sdParam("severity", string(notification.Severity)),
+	}
+	if notification.EntityKey != "" {
+		params = append(params, sdParam("entity", notification.EntityKey))
+	}
+
+	text := strings.Join(strings.Fields(common.MarkdownToText(notification.Body)), " ")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s [%s %s] %s%s",
+		s.facility*8+severity,
+		now.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		s.appName,
+		s.procID,
+		msgID,
+		sdID,
+		strings.Join(params, " "),
+		utf8BOM,
+		text,
+	)
+}
+
+func sdParam(name, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, escaped)
+}
+
+// headerField makes a header value printable ASCII without spaces within the length limit of RFC 5424
+func headerField(value string, limit int) string {
+	field := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > limit {
+		field = field[:limit]
+	}
+	if field == "" {
+		return nilValue
+	}
+	return field
+}
+
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
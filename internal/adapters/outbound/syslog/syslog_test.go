@@ -0,0 +1,208 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+var notification = domain.Notification{
+	Body:      "*main*\nis red",
+	Severity:  domain.SeverityCritical,
+	Webhook:   "ci",
+	Event:     "workflow_run",
+	EntityKey: `run "1"]`,
+}
+
+func TestFormat(t *testing.T) {
+	sender, err := NewSender(Settings{Address: "localhost:514", Facility: "daemon", AppName: "relay app", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.procID = "42"
+
+	got := sender.format(notification, time.Date(2024, 3, 31, 1, 30, 0, 500_000_000, time.FixedZone("CET", 3600)))
+	// daemon (3) * 8 + critical (2)
+	want := `<26>1 2024-03-31T00:30:00.5Z host relay_app 42 workflow_run ` +
+		`[hookrelay@32473 webhook="ci" event="workflow_run" severity="critical" entity="run \"1\"\]"] ` +
+		utf8BOM + "main is red"
+	if got != want {
+		t.Errorf("format() =\n%q\nwant\n%q", got, want)
+	}
+
+	// defaults and nil values
+	got = sender.format(domain.Notification{Body: "hi"}, time.Unix(0, 0))
+	if !strings.HasPrefix(got, `<30>1 1970-01-01T00:00:00Z host relay_app 42 - [hookrelay@32473 webhook="" event="" severity=""] `) {
+		t.Errorf("format() = %q, want info severity and a nil MSGID", got)
+	}
+}
+
+func TestSendUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sender, err := NewSender(Settings{Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if err := sender.Send(context.Background(), config.Recipient{Name: "siem"}, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one datagram per message without framing, local0 (16) * 8 + critical (2)
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<130>1 ") || !strings.HasSuffix(msg, "main is red") {
+		t.Errorf("datagram = %q", msg)
+	}
+}
+
+// readFrames reads octet-counted messages from every connection to listener
+func readFrames(t *testing.T, listener net.Listener, dropAfterFirst bool) <-chan string {
+	t.Helper()
+	frames := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSpace(length))
+					if err != nil {
+						t.Errorf("invalid frame length %q", length)
+						return
+					}
+					msg := make([]byte, n)
+					if _, err := io.ReadFull(r, msg); err != nil {
+						return
+					}
+					frames <- string(msg)
+					if dropAfterFirst {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return frames
+}
+
+func receive(t *testing.T, frames <-chan string) string {
+	t.Helper()
+	select {
+	case msg := <-frames:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestSendTCPOctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	frames := readFrames(t, listener, false)
+
+	sender, err := NewSender(Settings{Network: NetworkTCP, Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	for _, body := range []string{"first", "second line\nwith a newline"} {
+		n := notification
+		n.Body = body
+		if err := sender.Send(context.Background(), config.Recipient{Name: "siem"}, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the length prefix keeps messages apart over one stream
+	if msg := receive(t, frames); !strings.HasSuffix(msg, utf8BOM+"first") {
+		t.Errorf("first message = %q", msg)
+	}
+	if msg := receive(t, frames); !strings.HasSuffix(msg, utf8BOM+"second line with a newline") {
+		t.Errorf("second message = %q", msg)
+	}
+}
+
+func TestSendTCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	frames := readFrames(t, listener, true)
+
+	sender, err := NewSender(Settings{Network: NetworkTCP, Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if err := sender.Send(context.Background(), config.Recipient{Name: "siem"}, notification); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, frames)
+
+	// the collector closed the connection, a write fails once it notices
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := sender.Send(context.Background(), config.Recipient{Name: "siem"}, notification); err != nil {
+			t.Fatalf("Send() after the collector closed the connection: %v", err)
+		}
+		select {
+		case <-frames:
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	t.Fatal("no message received after reconnecting")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  string
+	}{
+		{name: "valid", settings: Settings{Address: "localhost:514", Network: NetworkTLS, Facility: "local3"}},
+		{name: "no address", settings: Settings{}, wantErr: "empty 'address' value"},
+		{name: "unknown network", settings: Settings{Address: "localhost:514", Network: "unix"}, wantErr: "unknown network 'unix'"},
+		{name: "unknown facility", settings: Settings{Address: "localhost:514", Facility: "local8"}, wantErr: "unknown facility 'local8'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.settings)
+			if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+)
+
+const (
+	DefaultAPIURL = "https://api.telegram.org"
+
+	defaultMaxRetries   = 3
+	defaultGlobalRate   = 30 // messages per second across all chats
+	defaultChatInterval = time.Second
+	retryBaseDelay      = 500 * time.Millisecond
+)
+
+// apiResponse is the envelope of every Bot API response
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// apiError is a failed Bot API call
+type apiError struct {
+	StatusCode int
+	Response   string
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("telegram API error: status %d, response: %s", e.StatusCode, e.Response)
+}
+
+func (e *apiError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// call invokes a Bot API method for a chat, waiting for a free rate limit slot and retrying
+// after 429 and 5xx responses. The result is decoded into result unless it is nil.
+func (s *Sender) call(ctx context.Context, chatID, method, contentType string, body []byte, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		if err := s.limiter.wait(ctx, chatID); err != nil {
+			return err
+		}
+
+		err := s.do(ctx, s.client, method, contentType, body, result)
+
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || !apiErr.retryable() || attempt >= s.maxRetries {
+			return err
+		}
+
+		delay := apiErr.RetryAfter
+		if delay > 0 {
+			s.limiter.backoff(delay)
+		} else {
+			delay = retryBaseDelay << attempt
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+		log.FromContext(ctx).Warn().
+			Str("method", method).
+			Str("chat_id", chatID).
+			Str("retry_in", delay.String()).
+			Err(err).
+			Msg("telegram API call failed, retrying")
+	}
+}
+
+func (s *Sender) do(ctx context.Context, client *http.Client, method, contentType string, body []byte, result interface{}) error {
+	apiURL := fmt.Sprintf("%s/bot%s/%s", s.apiURL, s.token, method)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var envelope apiResponse
+	decodeErr := json.Unmarshal(respBody, &envelope)
+
+	if resp.StatusCode != http.StatusOK || (decodeErr == nil && !envelope.OK) {
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Response:   string(respBody),
+			RetryAfter: time.Duration(envelope.Parameters.RetryAfter) * time.Second,
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func isParseError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Response, "can't parse entities")
+}
+
+// isNotModifiedError reports an edit that would not change the message
+func isNotModifiedError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Response, "message is not modified")
+}
@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// chatState is the routing a chat has set up with bot commands
+type chatState struct {
+	Muted      bool      `json:"muted,omitempty"` // muted until /unmute
+	MutedUntil time.Time `json:"muted_until,omitempty"`
+	// Events lists the delivered events per webhook. A webhook that is not listed delivers all
+	// events, an empty list delivers none.
+	Events map[string][]string `json:"events,omitempty"`
+}
+
+func (c chatState) mutedAt(now time.Time) bool {
+	return c.Muted || now.Before(c.MutedUntil)
+}
+
+func (c chatState) subscribed(webhook, event string) bool {
+	events, ok := c.Events[webhook]
+	switch {
+	case !ok:
+		return true
+	case len(events) == 0:
+		return false // unsubscribed from the webhook, digests included
+	case event == "":
+		return true
+	}
+	return slices.Contains(events, event)
+}
+
+// chatStates caches the chat states persisted in the store
+type chatStates struct {
+	mu       sync.Mutex
+	store    ports.Store
+	botID    string
+	webhooks map[string][]config.WebhookName // chat id → webhooks routed to it
+	cache    map[string]chatState
+}
+
+func newChatStates(store ports.Store, botID string, webhooks map[string][]config.WebhookName) *chatStates {
+	return &chatStates{
+		store:    store,
+		botID:    botID,
+		webhooks: webhooks,
+		cache:    make(map[string]chatState),
+	}
+}
+
+func (cs *chatStates) key(chatID string) string {
+	return fmt.Sprintf("telegram:%s:chat:%s", cs.botID, chatID)
+}
+
+func (cs *chatStates) get(ctx context.Context, chatID string) (chatState, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.load(ctx, chatID)
+}
+
+// update changes the state of a chat and persists it
+func (cs *chatStates) update(ctx context.Context, chatID string, change func(*chatState)) (chatState, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	state, err := cs.load(ctx, chatID)
+	if err != nil {
+		return chatState{}, err
+	}
+	change(&state)
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return chatState{}, fmt.Errorf("failed to marshal chat state: %w", err)
+	}
+	if err := cs.store.Set(ctx, cs.key(chatID), string(data)); err != nil {
+		return chatState{}, err
+	}
+	cs.cache[chatID] = state
+	return state, nil
+}
+
+func (cs *chatStates) load(ctx context.Context, chatID string) (chatState, error) {
+	if state, ok := cs.cache[chatID]; ok {
+		return state, nil
+	}
+
+	var state chatState
+	value, ok, err := cs.store.Get(ctx, cs.key(chatID))
+	if err != nil {
+		return chatState{}, err
+	}
+	if ok {
+		if err := json.Unmarshal([]byte(value), &state); err != nil {
+			return chatState{}, fmt.Errorf("failed to decode chat state: %w", err)
+		}
+	}
+	cs.cache[chatID] = state
+	return state, nil
+}
+
+// Accepts drops the notifications a chat has muted or unsubscribed from with bot commands.
+// Critical notifications pass a mute, but not an unsubscribe.
+func (s *Sender) Accepts(ctx context.Context, recipient config.Recipient, notification domain.Notification) bool {
+	if !s.commands {
+		return true
+	}
+
+	state, err := s.chats.get(ctx, recipient.Target)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Str("recipient", recipient.Name).Msg("failed to load chat state")
+		return true
+	}
+
+	if !state.subscribed(notification.Webhook, notification.Event) {
+		return false
+	}
+	return !state.mutedAt(time.Now()) || notification.Severity.AtLeast(domain.SeverityCritical)
+}
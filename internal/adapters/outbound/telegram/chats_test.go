@@ -0,0 +1,28 @@
+package telegram
+
+import "testing"
+
+func TestChatStateSubscribed(t *testing.T) {
+	state := chatState{Events: map[string][]string{
+		"github":   {"push"},
+		"kanboard": {},
+	}}
+
+	tests := []struct {
+		webhook, event string
+		want           bool
+	}{
+		{"gitlab", "push", true},
+		{"gitlab", "", true},
+		{"github", "push", true},
+		{"github", "issues", false},
+		{"github", "", true},
+		{"kanboard", "task.create", false},
+		{"kanboard", "", false},
+	}
+	for _, tt := range tests {
+		if got := state.subscribed(tt.webhook, tt.event); got != tt.want {
+			t.Errorf("subscribed(%q, %q) = %v, want %v", tt.webhook, tt.event, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/config"
+)
+
+const (
+	defaultPollTimeout = 30 * time.Second
+	pollRetryDelay     = 5 * time.Second
+)
+
+const commandHelp = `Commands:
+/mute [2h|30m|1d] - pause notifications, until /unmute without a duration
+/unmute - resume notifications
+/subscribe <webhook> [event...] - receive all or only the given events of a webhook
+/unsubscribe <webhook> [event...] - stop receiving a webhook or some of its events
+/status - show the settings of this chat
+/test - send a test message`
+
+// update is the part of a Bot API Update the command loop needs
+type update struct {
+	UpdateID int `json:"update_id"`
+	Message  *struct {
+		MessageThreadID int    `json:"message_thread_id"`
+		Text            string `json:"text"`
+		Chat            struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Run polls for bot commands until ctx is cancelled. It returns at once if commands are disabled.
+func (s *Sender) Run(ctx context.Context) error {
+	if !s.commands {
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Info().Msg("polling for telegram bot commands")
+
+	offset := 0
+	for {
+		updates, err := s.getUpdates(ctx, offset)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to get telegram updates")
+			if err := sleep(ctx, pollRetryDelay); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil {
+				continue
+			}
+			chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+			reply := s.handleCommand(ctx, chatID, u.Message.Text)
+			if reply == "" {
+				continue
+			}
+			opts := messageOptions{target: Options{MessageThreadID: u.Message.MessageThreadID}}
+			if _, err := s.trySend(ctx, chatID, reply, "", opts); err != nil {
+				logger.Warn().Err(err).Str("chat_id", chatID).Msg("failed to reply to telegram command")
+			}
+		}
+	}
+}
+
+func (s *Sender) getUpdates(ctx context.Context, offset int) ([]update, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(s.pollTimeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal json payload: %w", err)
+	}
+
+	var updates []update
+	if err := s.do(ctx, s.pollClient, "getUpdates", "application/json", payload, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// handleCommand applies a bot command sent to a chat and returns the reply, empty if the
+// message is not a command or the chat is not a recipient of the notifier
+func (s *Sender) handleCommand(ctx context.Context, chatID, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+	webhooks, ok := s.chats.webhooks[chatID]
+	if !ok {
+		return ""
+	}
+
+	command, _, _ := strings.Cut(strings.ToLower(fields[0]), "@") // '/mute@hookrelay_bot' in groups
+	args := fields[1:]
+
+	logger := log.FromContext(ctx).With(log.Str("chat_id", chatID))
+	logger.Info().Str("command", command).Msg("received telegram bot command")
+
+	var state chatState
+	var err error
+	var reply string
+	switch command {
+	case "/mute":
+		var until time.Time
+		if len(args) > 0 {
+			d, parseErr := parseMuteDuration(args[0])
+			if parseErr != nil {
+				return fmt.Sprintf("Invalid duration '%s', use e.g. 30m, 2h or 1d.", args[0])
+			}
+			until = time.Now().Add(d)
+		}
+		state, err = s.chats.update(ctx, chatID, func(c *chatState) {
+			c.Muted = until.IsZero()
+			c.MutedUntil = until
+		})
+		reply = "🔕 Muted until /unmute. Critical notifications are still delivered."
+		if !until.IsZero() {
+			reply = fmt.Sprintf("🔕 Muted until %s. Critical notifications are still delivered.", until.UTC().Format("2006-01-02 15:04 MST"))
+		}
+	case "/unmute":
+		state, err = s.chats.update(ctx, chatID, func(c *chatState) {
+			c.Muted = false
+			c.MutedUntil = time.Time{}
+		})
+		reply = "🔔 Notifications resumed."
+	case "/subscribe", "/unsubscribe":
+		if len(args) == 0 {
+			return fmt.Sprintf("Usage: %s <webhook> [event...]\n\n%s", command, formatWebhooks(webhooks))
+		}
+		webhook := args[0]
+		if !slices.Contains(webhooks, config.WebhookName(webhook)) {
+			return fmt.Sprintf("Webhook '%s' is not routed to this chat.\n\n%s", webhook, formatWebhooks(webhooks))
+		}
+		events := args[1:]
+		if command == "/unsubscribe" && len(events) > 0 {
+			if current, _ := s.chats.get(ctx, chatID); current.Events[webhook] == nil {
+				return fmt.Sprintf("This chat receives all events of '%s', pick the events to keep with /subscribe %s <event...>.", webhook, webhook)
+			}
+		}
+		state, err = s.chats.update(ctx, chatID, func(c *chatState) {
+			if c.Events == nil {
+				c.Events = make(map[string][]string)
+			}
+			switch {
+			case command == "/subscribe" && len(events) == 0:
+				delete(c.Events, webhook)
+			case command == "/subscribe":
+				for _, event := range events {
+					if !slices.Contains(c.Events[webhook], event) {
+						c.Events[webhook] = append(c.Events[webhook], event)
+					}
+				}
+			case len(events) == 0:
+				c.Events[webhook] = []string{}
+			default:
+				c.Events[webhook] = slices.DeleteFunc(c.Events[webhook], func(event string) bool {
+					return slices.Contains(events, event)
+				})
+			}
+		})
+		reply = "✅ " + formatSubscription(state, webhook)
+	case "/status":
+		state, err = s.chats.get(ctx, chatID)
+		reply = formatStatus(state, webhooks)
+	case "/test":
+		return "✅ Test notification from hookrelay, this chat is set up correctly."
+	case "/help", "/start":
+		return commandHelp
+	default:
+		return ""
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Str("command", command).Msg("failed to update chat state")
+		return "❌ Failed to save the chat settings, please try again later."
+	}
+	return reply
+}
+
+// parseMuteDuration accepts Go durations such as '30m' or '2h' and whole days such as '1d'
+func parseMuteDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days '%s'", days)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
+
+func formatSubscription(state chatState, webhook string) string {
+	events, ok := state.Events[webhook]
+	switch {
+	case !ok:
+		return fmt.Sprintf("%s: all events", webhook)
+	case len(events) == 0:
+		return fmt.Sprintf("%s: unsubscribed", webhook)
+	default:
+		return fmt.Sprintf("%s: %s", webhook, strings.Join(events, ", "))
+	}
+}
+
+func formatWebhooks(webhooks []config.WebhookName) string {
+	names := make([]string, len(webhooks))
+	for i, webhook := range webhooks {
+		names[i] = string(webhook)
+	}
+	return "Webhooks of this chat: " + strings.Join(names, ", ")
+}
+
+func formatStatus(state chatState, webhooks []config.WebhookName) string {
+	var status strings.Builder
+	switch {
+	case state.Muted:
+		status.WriteString("🔕 Muted until /unmute\n")
+	case time.Now().Before(state.MutedUntil):
+		fmt.Fprintf(&status, "🔕 Muted until %s\n", state.MutedUntil.UTC().Format("2006-01-02 15:04 MST"))
+	default:
+		status.WriteString("🔔 Not muted\n")
+	}
+	for _, webhook := range webhooks {
+		status.WriteString("\n" + formatSubscription(state, string(webhook)))
+	}
+	return status.String()
+}
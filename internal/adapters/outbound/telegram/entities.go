@@ -0,0 +1,40 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+const (
+	EntityModeEdit  = "edit"  // replace the message of an entity with its latest state
+	EntityModeReply = "reply" // reply to the first message of an entity
+)
+
+// entityKey returns the store key of the message sent for an entity, empty if there is nothing to follow up on
+func (s *Sender) entityKey(recipient config.Recipient, notification domain.Notification) string {
+	if (s.entityMode != EntityModeEdit && s.entityMode != EntityModeReply) || notification.EntityKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("telegram:%s:%s:%s", notification.Webhook, notification.EntityKey, recipient.Name)
+}
+
+func (s *Sender) entityMessage(ctx context.Context, key string) (int, bool) {
+	value, ok, err := s.store.Get(ctx, key)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Str("key", key).Msg("failed to look up entity message")
+		return 0, false
+	}
+	if !ok {
+		return 0, false
+	}
+	messageID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return messageID, true
+}
@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out Bot API calls to respect the global and per-chat limits of Telegram.
+// Every call reserves the next free slot, so messages to the same chat are sent in the order they arrived.
+type rateLimiter struct {
+	mu             sync.Mutex
+	globalInterval time.Duration
+	chatInterval   time.Duration
+	globalNext     time.Time
+	chatNext       map[string]time.Time
+}
+
+func newRateLimiter(globalInterval, chatInterval time.Duration) *rateLimiter {
+	return &rateLimiter{
+		globalInterval: globalInterval,
+		chatInterval:   chatInterval,
+		chatNext:       make(map[string]time.Time),
+	}
+}
+
+// wait blocks until the reserved slot for the chat comes up
+func (l *rateLimiter) wait(ctx context.Context, chatID string) error {
+	return sleep(ctx, time.Until(l.reserve(chatID)))
+}
+
+func (l *rateLimiter) reserve(chatID string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	slot := now
+	if l.globalNext.After(slot) {
+		slot = l.globalNext
+	}
+	if next, ok := l.chatNext[chatID]; ok && next.After(slot) {
+		slot = next
+	}
+	l.globalNext = slot.Add(l.globalInterval)
+	l.chatNext[chatID] = slot.Add(l.chatInterval)
+
+	// forget chats that have been idle, their slots are in the past anyway
+	for id, next := range l.chatNext {
+		if next.Before(now) {
+			delete(l.chatNext, id)
+		}
+	}
+	return slot
+}
+
+// backoff pushes the next slot of every chat back after a 429 response with retry_after.
+// Telegram does not tell whether the flood limit of the chat or of the whole bot was hit, so all chats wait.
+func (l *rateLimiter) backoff(delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(delay)
+	if l.globalNext.Before(until) {
+		l.globalNext = until
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
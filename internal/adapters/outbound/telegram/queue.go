@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/config"
+)
+
+const defaultQueueSize = 100
+
+var errQueueClosed = errors.New("telegram sender is closed")
+
+// sendQueue sends the messages of every chat in order on a worker of its own, so webhook requests
+// do not wait for rate limits and retries. The workers run on a background context and outlive the
+// request that queued a message.
+type sendQueue struct {
+	size int // max messages waiting per chat
+
+	mu     sync.Mutex
+	chats  map[string][]sendJob // waiting messages by chat, a worker runs while a chat has an entry
+	closed bool
+	idle   chan struct{} // closed when the last worker exits, drain waits on it
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+type sendJob struct {
+	logger    log.Logger
+	recipient config.Recipient
+	run       func(ctx context.Context) error
+}
+
+func newSendQueue(size int) *sendQueue {
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sendQueue{
+		size:   size,
+		chats:  make(map[string][]sendJob),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// push queues a message for the chat of the recipient. It fails at once if the queue of the chat is full.
+func (q *sendQueue) push(ctx context.Context, recipient config.Recipient, run func(ctx context.Context) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errQueueClosed
+	}
+	chatID := recipient.Target
+	jobs, running := q.chats[chatID]
+	if len(jobs) >= q.size {
+		return fmt.Errorf("send queue of chat %s is full (%d messages)", chatID, q.size)
+	}
+	q.chats[chatID] = append(jobs, sendJob{logger: log.FromContext(ctx), recipient: recipient, run: run})
+	if !running {
+		if len(q.chats) == 1 {
+			q.idle = make(chan struct{})
+		}
+		q.workers.Add(1)
+		go q.work(chatID)
+	}
+	return nil
+}
+
+// work sends the messages of a chat until its queue is empty
+func (q *sendQueue) work(chatID string) {
+	defer q.workers.Done()
+
+	for {
+		q.mu.Lock()
+		jobs := q.chats[chatID]
+		if len(jobs) == 0 {
+			delete(q.chats, chatID)
+			if len(q.chats) == 0 {
+				close(q.idle)
+			}
+			q.mu.Unlock()
+			return
+		}
+		job := jobs[0]
+		q.chats[chatID] = jobs[1:]
+		q.mu.Unlock()
+
+		if err := job.run(log.NewContext(q.ctx, job.logger)); err != nil {
+			job.logger.Error().
+				Str("recipient", job.recipient.Name).
+				Str("notifier_name", string(job.recipient.Notifier)).
+				Str("chat_id", chatID).
+				Err(err).
+				Msg("failed to send notification")
+		}
+	}
+}
+
+// drain waits until every queued message is sent, including the ones queued meanwhile
+func (q *sendQueue) drain(ctx context.Context) error {
+	q.mu.Lock()
+	if len(q.chats) == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := q.idle
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		// more messages may have been queued since, wait for them too
+		return q.drain(ctx)
+	case <-ctx.Done():
+		return fmt.Errorf("telegram messages are still queued: %w", ctx.Err())
+	}
+}
+
+// close stops taking messages, drops the waiting ones and cancels the ones being sent.
+// Call drain first to send them.
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	for chatID, jobs := range q.chats {
+		if len(jobs) > 0 {
+			jobs[0].logger.Warn().Str("chat_id", chatID).Int("count", len(jobs)).Msg("dropped queued telegram messages on close")
+		}
+		q.chats[chatID] = nil
+	}
+	q.mu.Unlock()
+
+	q.cancel()
+	q.workers.Wait()
+}
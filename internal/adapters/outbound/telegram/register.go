@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const Type config.NotifierType = "telegram"
+
+type Settings struct {
+	Token        string        `mapstructure:"token"`
+	LongMessages string        `mapstructure:"long_messages"` // 'split' (default) or 'document' for texts over 4096 characters
+	APIURL       string        `mapstructure:"api_url"`       // Bot API server, 'https://api.telegram.org' by default
+	MaxRetries   int           `mapstructure:"max_retries"`   // retries after 429 and 5xx responses, 3 by default; -1 disables retries
+	GlobalRate   int           `mapstructure:"global_rate"`   // messages per second across all chats, 30 by default
+	ChatInterval time.Duration `mapstructure:"chat_interval"` // minimum gap between messages to one chat, '1s' by default
+	QueueSize    int           `mapstructure:"queue_size"`    // messages waiting per chat before new ones are rejected, 100 by default
+	EntityMode   string        `mapstructure:"entity_mode"`   // 'edit' or 'reply' to follow up on the message of a PR, issue or task; off by default
+	Commands     bool          `mapstructure:"commands"`      // poll for /mute, /subscribe and other bot commands from recipient chats
+	PollTimeout  time.Duration `mapstructure:"poll_timeout"`  // long polling timeout of getUpdates, '30s' by default
+}
+
+// Options holds the recipient options of a Telegram chat
+type Options struct {
+	MessageThreadID       int  `mapstructure:"message_thread_id"` // forum topic of a supergroup
+	DisableNotification   bool `mapstructure:"disable_notification"`
+	DisableWebPagePreview bool `mapstructure:"disable_web_page_preview"`
+	ProtectContent        bool `mapstructure:"protect_content"`
+}
+
+func init() {
+	registry.RegisterNotifier(Type, registry.Notifier[Settings]{
+		Validate: Validate,
+		ValidateRecipient: func(_ Settings, recipient config.Recipient) error {
+			return ValidateRecipient(recipient)
+		},
+		New: func(name config.NotifierName, settings Settings, env registry.Env) (ports.Notifier, error) {
+			return NewSender(settings, env.Store, recipientWebhooks(env.Config, name)), nil
+		},
+	})
+}
+
+// recipientWebhooks maps the targets of the recipients of a notifier to the webhooks routed to them
+func recipientWebhooks(cfg *config.Config, notifierName config.NotifierName) map[string][]config.WebhookName {
+	targets := make(map[string]string) // recipient name → target
+	for _, recipient := range cfg.Recipients {
+		if recipient.Notifier == notifierName {
+			targets[recipient.Name] = recipient.Target
+		}
+	}
+
+	webhooks := make(map[string][]config.WebhookName)
+	for _, target := range targets {
+		webhooks[target] = nil
+	}
+	for _, webhookCfg := range cfg.Webhooks {
+		for _, name := range webhookCfg.Recipients {
+			target, ok := targets[name]
+			if ok && !slices.Contains(webhooks[target], webhookCfg.Name) {
+				webhooks[target] = append(webhooks[target], webhookCfg.Name)
+			}
+		}
+	}
+	return webhooks
+}
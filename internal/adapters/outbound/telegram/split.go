@@ -0,0 +1,247 @@
+package telegram
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	maxMessageLength  = 4096 // Telegram limit in UTF-16 code units after entity parsing
+	partHeaderReserve = 16   // room for the "1/3" part number line
+	codeFence         = "```"
+)
+
+// messageLength estimates the length of text as Telegram counts it after MarkdownV2 escaping
+func messageLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length += runeLength(r)
+	}
+	return length
+}
+
+func runeLength(r rune) int {
+	length := utf16.RuneLen(r)
+	if length < 0 {
+		length = 1
+	}
+	if strings.ContainsRune(markdownV2Escaped, r) {
+		length++
+	}
+	return length
+}
+
+// splitMessage splits text into parts that fit the limit once escaped.
+// It prefers paragraph and line boundaries, and closes and reopens code blocks that span parts.
+func splitMessage(text string, limit int) []string {
+	fenceReserve := messageLength("\n" + codeFence)
+
+	var parts []string
+	var chunk []string
+	var fences []bool // whether a code block is open after each line of the chunk
+	size := 0
+
+	emit := func(n int) {
+		open := fences[n-1]
+		part := strings.Join(chunk[:n], "\n")
+		if open {
+			part += "\n" + codeFence
+		}
+		if part = strings.Trim(part, "\n"); part != "" {
+			parts = append(parts, part)
+		}
+
+		restLines, restFences := chunk[n:], fences[n:]
+		for len(restLines) > 0 && strings.TrimSpace(restLines[0]) == "" {
+			restLines, restFences = restLines[1:], restFences[1:]
+		}
+
+		chunk, fences = nil, nil
+		if open {
+			chunk, fences = append(chunk, codeFence), append(fences, true)
+		}
+		chunk, fences = append(chunk, restLines...), append(fences, restFences...)
+		size = messageLength(strings.Join(chunk, "\n"))
+	}
+
+	open := false
+	for _, line := range splitLongLines(strings.Split(text, "\n"), limit-fenceReserve-messageLength(codeFence+"\n")) {
+		lineSize := messageLength(line)
+		if len(chunk) > 0 && size+1+lineSize+fenceReserve > limit {
+			emit(cutPoint(chunk, fences, limit))
+			if len(chunk) > 0 && size+1+lineSize+fenceReserve > limit {
+				emit(len(chunk))
+			}
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			open = !open
+		}
+		if len(chunk) > 0 {
+			size++
+		}
+		chunk, fences = append(chunk, line), append(fences, open)
+		size += lineSize
+	}
+	if len(chunk) > 0 {
+		emit(len(chunk))
+	}
+	return parts
+}
+
+// cutPoint prefers to end a part at the last paragraph break outside code blocks in its second half
+func cutPoint(chunk []string, fences []bool, limit int) int {
+	size := 0
+	best := len(chunk)
+	for i, line := range chunk {
+		size += messageLength(line) + 1
+		if i > 0 && strings.TrimSpace(line) == "" && !fences[i] && size >= limit/2 {
+			best = i
+		}
+	}
+	return best
+}
+
+// splitLongLines breaks lines that do not fit the limit on their own, see cutLine
+func splitLongLines(lines []string, limit int) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		for messageLength(line) > limit {
+			var head string
+			head, line = cutLine(line, limit)
+			result = append(result, head)
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// cutLine splits off the longest head of a line that fits the limit without breaking MarkdownV2 entities.
+// It cuts at the last space outside entities, else anywhere outside them. A line that is one long entity
+// is cut inside it: formatting is closed in the head and reopened in the rest, links are only cut as a
+// last resort. Escape pairs are never split.
+func cutLine(line string, limit int) (head, rest string) {
+	var st entityScanner
+	lastSpace, lastFree := 0, 0
+	formatted, formattedClose, formattedOpen := 0, "", ""
+	hard := 0
+
+	size := 0
+	for i := 0; i < len(line); {
+		if i > 0 {
+			hard = i
+			switch {
+			case st.free():
+				lastFree = i
+				if line[i] == ' ' {
+					lastSpace = i
+				}
+			case st.link == 0 && size+messageLength(st.closing()) <= limit:
+				formatted, formattedClose, formattedOpen = i, st.closing(), st.opening()
+			}
+		}
+
+		end := st.advance(line, i)
+		size += messageLength(line[i:end])
+		if size > limit {
+			break
+		}
+		i = end
+	}
+
+	switch {
+	case lastSpace > 0:
+		return line[:lastSpace], strings.TrimLeft(line[lastSpace:], " ")
+	case lastFree > 0:
+		return line[:lastFree], line[lastFree:]
+	case formatted > 0:
+		return line[:formatted] + formattedClose, formattedOpen + line[formatted:]
+	case hard > 0:
+		return line[:hard], line[hard:]
+	default:
+		// the first token alone is over the limit
+		end := st.advance(line, 0)
+		return line[:end], line[end:]
+	}
+}
+
+const (
+	linkText = iota + 1
+	linkURL
+)
+
+// entityScanner follows the MarkdownV2 entities opened and closed along a line
+type entityScanner struct {
+	markers []string // open formatting markers, innermost last
+	link    int      // linkText or linkURL inside [text](url)
+}
+
+// free reports whether no entity is open, so the line can be cut at the current position
+func (st *entityScanner) free() bool {
+	return len(st.markers) == 0 && st.link == 0
+}
+
+func (st *entityScanner) code() bool {
+	return len(st.markers) > 0 && st.markers[len(st.markers)-1] == "`"
+}
+
+// closing returns the markers ending the open formatting, opening the ones starting it again
+func (st *entityScanner) closing() string {
+	var b strings.Builder
+	for i := len(st.markers) - 1; i >= 0; i-- {
+		b.WriteString(st.markers[i])
+	}
+	return b.String()
+}
+
+func (st *entityScanner) opening() string {
+	return strings.Join(st.markers, "")
+}
+
+func (st *entityScanner) toggle(marker string) {
+	if i := slices.Index(st.markers, marker); i >= 0 {
+		st.markers = slices.Delete(st.markers, i, i+1)
+		return
+	}
+	st.markers = append(st.markers, marker)
+}
+
+// advance consumes the token at line[i:], an escape pair, a marker or a single rune, and returns its end
+func (st *entityScanner) advance(line string, i int) int {
+	r, n := utf8.DecodeRuneInString(line[i:])
+	end := i + n
+
+	switch {
+	case r == '\\' && end < len(line):
+		_, n := utf8.DecodeRuneInString(line[end:])
+		return end + n
+	case st.code():
+		if r == '`' {
+			st.toggle("`")
+		}
+	case r == '`':
+		st.toggle("`")
+	case st.link == linkURL:
+		if r == ')' {
+			st.link = 0
+		}
+	case r == '[' && st.link == 0:
+		st.link = linkText
+	case r == ']' && st.link == linkText:
+		st.link = 0
+		if strings.HasPrefix(line[end:], "(") {
+			st.link = linkURL
+			return end + 1
+		}
+	case r == '*':
+		st.toggle("*")
+	case r == '_' && strings.HasPrefix(line[end:], "_"):
+		st.toggle("__")
+		return end + 1
+	case r == '_':
+		st.toggle("_")
+	}
+	return end
+}
@@ -0,0 +1,155 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCutLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		limit    int
+		wantHead string
+		wantRest string
+	}{
+		{
+			name:     "at the last space",
+			line:     "one two three four",
+			limit:    10,
+			wantHead: "one two",
+			wantRest: "three four",
+		},
+		{
+			name:     "not inside a link",
+			line:     "see [the docs](https://example.com/a/b) now",
+			limit:    20,
+			wantHead: "see",
+			wantRest: "[the docs](https://example.com/a/b) now",
+		},
+		{
+			name:     "not inside bold",
+			line:     "status *build failed badly* here",
+			limit:    20,
+			wantHead: "status",
+			wantRest: "*build failed badly* here",
+		},
+		{
+			name:     "not inside italic",
+			line:     "by _some long author name_ today",
+			limit:    18,
+			wantHead: "by",
+			wantRest: "_some long author name_ today",
+		},
+		{
+			name:     "not inside underline",
+			line:     "a __very long underlined text__ b",
+			limit:    20,
+			wantHead: "a",
+			wantRest: "__very long underlined text__ b",
+		},
+		{
+			name:     "not inside inline code",
+			line:     "run `go test ./... -run X` again",
+			limit:    20,
+			wantHead: "run",
+			wantRest: "`go test ./... -run X` again",
+		},
+		{
+			name:     "escape pair kept together",
+			line:     `abcdefghi\*rest`,
+			limit:    10,
+			wantHead: "abcdefghi",
+			wantRest: `\*rest`,
+		},
+		{
+			name:     "escaped markers are text",
+			line:     `x \*not bold\* tail end`,
+			limit:    14,
+			wantHead: `x \*not bold\*`,
+			wantRest: "tail end",
+		},
+		{
+			name:     "bold line closed and reopened",
+			line:     "*abcdefghijklmnopqrstuvwxyz*",
+			limit:    10,
+			wantHead: "*abcdefgh*",
+			wantRest: "*ijklmnopqrstuvwxyz*",
+		},
+		{
+			name:     "nested formatting closed in reverse order",
+			line:     "*_abcdefghijklmnop_*",
+			limit:    10,
+			wantHead: "*_abcdef_*",
+			wantRest: "*_ghijklmnop_*",
+		},
+		{
+			name:     "link longer than the limit",
+			line:     "[abcdefghijklmnopqrstuvwxyz](u)",
+			limit:    10,
+			wantHead: "[abcdefghi",
+			wantRest: "jklmnopqrstuvwxyz](u)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, rest := cutLine(tt.line, tt.limit)
+			if head != tt.wantHead || rest != tt.wantRest {
+				t.Errorf("cutLine(%q, %d) = %q, %q, want %q, %q", tt.line, tt.limit, head, rest, tt.wantHead, tt.wantRest)
+			}
+			if messageLength(head) > tt.limit {
+				t.Errorf("head %q is over the limit %d", head, tt.limit)
+			}
+		})
+	}
+}
+
+func TestSplitMessageKeepsEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "links", text: strings.Repeat("see [commit abc](https://github.com/octo/repo/commit/abc) ", 200)},
+		{name: "bold and italic", text: strings.Repeat("*bold words here* and _italic words here_ ", 200)},
+		{name: "escapes", text: strings.Repeat(`a\*b\_c\[d\] `, 600)},
+		{name: "one bold line", text: "*" + strings.Repeat("x", 9000) + "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitMessage(tt.text, 1000)
+			if len(parts) < 2 {
+				t.Fatalf("got %d parts, want several", len(parts))
+			}
+			for i, part := range parts {
+				if messageLength(part) > 1000 {
+					t.Errorf("part %d is %d long, over the limit", i, messageLength(part))
+				}
+				var st entityScanner
+				for j := 0; j < len(part); {
+					j = st.advance(part, j)
+				}
+				if !st.free() {
+					t.Errorf("part %d leaves entities open: %q ... %q", i, part[:20], part[len(part)-20:])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageCodeBlocks(t *testing.T) {
+	lines := []string{"intro", codeFence}
+	for i := 0; i < 300; i++ {
+		lines = append(lines, "fmt.Println(\"line of code\")")
+	}
+	lines = append(lines, codeFence, "outro")
+
+	parts := splitMessage(strings.Join(lines, "\n"), 1000)
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want several", len(parts))
+	}
+	for i, part := range parts {
+		if n := strings.Count(part, codeFence); n%2 != 0 {
+			t.Errorf("part %d has %d code fences, want them balanced", i, n)
+		}
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,91 +19,414 @@ import (
 	"github.com/shanth1/hookrelay/internal/core/ports"
 )
 
-var _ ports.Notifier = (*Sender)(nil)
+const (
+	LongMessagesSplit    = "split"
+	LongMessagesDocument = "document"
+
+	maxCaptionLength = 1024
+	maxButtonsPerRow = 3
+	documentFileName = "message.txt"
+)
+
+var (
+	_ ports.Notifier        = (*Sender)(nil)
+	_ ports.RecipientFilter = (*Sender)(nil)
+	_ ports.Runner          = (*Sender)(nil)
+	_ ports.Drainer         = (*Sender)(nil)
+	_ io.Closer             = (*Sender)(nil)
+)
+
+// usernamePattern matches the public username of a channel or supergroup, without the '@'
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
 
 type Sender struct {
-	client *http.Client
-	token  string
+	client       *http.Client
+	pollClient   *http.Client
+	apiURL       string
+	token        string
+	longMessages string
+	maxRetries   int
+	limiter      *rateLimiter
+	queue        *sendQueue
+	entityMode   string
+	store        ports.Store
+	commands     bool
+	pollTimeout  time.Duration
+	chats        *chatStates
+}
+
+func Validate(cfg Settings) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("empty 'token' value")
+	}
+	switch cfg.LongMessages {
+	case "", LongMessagesSplit, LongMessagesDocument:
+	default:
+		return fmt.Errorf("unknown long_messages mode '%s'", cfg.LongMessages)
+	}
+	switch cfg.EntityMode {
+	case "", EntityModeEdit, EntityModeReply:
+		return nil
+	default:
+		return fmt.Errorf("unknown entity_mode '%s'", cfg.EntityMode)
+	}
+}
+
+// ValidateRecipient checks the chat id in the target and the options of a recipient
+func ValidateRecipient(recipient config.Recipient) error {
+	if err := validateChatID(recipient.Target); err != nil {
+		return err
+	}
+	var options Options
+	if err := recipient.DecodeOptionsStrict(&options); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if options.MessageThreadID < 0 {
+		return fmt.Errorf("negative 'message_thread_id' value")
+	}
+	return nil
+}
+
+// validateChatID accepts numeric chat ids and '@username' of public channels and supergroups
+func validateChatID(target string) error {
+	if target == "" {
+		return fmt.Errorf("empty 'target' value, expected a chat id")
+	}
+	if username, ok := strings.CutPrefix(target, "@"); ok {
+		if !usernamePattern.MatchString(username) {
+			return fmt.Errorf("invalid channel username '%s'", target)
+		}
+		return nil
+	}
+	if _, err := strconv.ParseInt(target, 10, 64); err != nil {
+		return fmt.Errorf("invalid chat id '%s', expected a number or '@username'", target)
+	}
+	return nil
 }
 
-func NewSender(cfg config.TelegramSettings) *Sender {
+// NewSender creates a Telegram notifier. chats maps the chat id of every recipient of the
+// notifier to the webhooks routed to it, only these chats may use bot commands.
+func NewSender(cfg Settings, store ports.Store, chats map[string][]config.WebhookName) *Sender {
+	longMessages := cfg.LongMessages
+	if longMessages == "" {
+		longMessages = LongMessagesSplit
+	}
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	globalRate := cfg.GlobalRate
+	if globalRate <= 0 {
+		globalRate = defaultGlobalRate
+	}
+	chatInterval := cfg.ChatInterval
+	if chatInterval == 0 {
+		chatInterval = defaultChatInterval
+	}
+	pollTimeout := cfg.PollTimeout
+	if pollTimeout <= 0 {
+		pollTimeout = defaultPollTimeout
+	}
+	botID, _, _ := strings.Cut(cfg.Token, ":")
+
 	return &Sender{
-		client: &http.Client{Timeout: 10 * time.Second},
-		token:  cfg.Token,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		pollClient:   &http.Client{Timeout: pollTimeout + 10*time.Second},
+		apiURL:       apiURL,
+		token:        cfg.Token,
+		longMessages: longMessages,
+		maxRetries:   maxRetries,
+		limiter:      newRateLimiter(time.Second/time.Duration(globalRate), chatInterval),
+		queue:        newSendQueue(cfg.QueueSize),
+		entityMode:   cfg.EntityMode,
+		store:        store,
+		commands:     cfg.Commands,
+		pollTimeout:  pollTimeout,
+		chats:        newChatStates(store, botID, chats),
+	}
+}
+
+// messageOptions are the parameters shared by all messages sent for one notification
+type messageOptions struct {
+	target        Options
+	silent        bool
+	buttons       []domain.Button
+	replyTo       int // message to reply to
+	editMessageID int // message to replace instead of sending a new one
+}
+
+// message is the part of a Bot API Message the sender needs
+type message struct {
+	MessageID int `json:"message_id"`
+}
+
+// Send queues the notification for the chat of the recipient and returns without waiting for it to be sent.
+// Messages to a chat go out in order, failures are logged by the queue with the recipient and notifier.
+func (s *Sender) Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error {
+	var target Options
+	if err := recipient.DecodeOptions(&target); err != nil {
+		return fmt.Errorf("failed to decode telegram options of '%s': %w", recipient.Name, err)
 	}
+
+	return s.queue.push(ctx, recipient, func(ctx context.Context) error {
+		return s.send(ctx, recipient, notification, target)
+	})
+}
+
+// Drain waits for the queued messages to be sent until ctx is done
+func (s *Sender) Drain(ctx context.Context) error {
+	return s.queue.drain(ctx)
+}
+
+// Close drops the messages still queued and cancels the ones being sent, call Drain first to send them
+func (s *Sender) Close() error {
+	s.queue.close()
+	return nil
 }
 
-func (s *Sender) Send(ctx context.Context, chatID string, notification domain.Notification) error {
-	escapedText := escapeMarkdownV2Selective(notification.Body)
+func (s *Sender) send(ctx context.Context, recipient config.Recipient, notification domain.Notification, target Options) error {
+	chatID := recipient.Target
+	opts := messageOptions{
+		target:  target,
+		silent:  notification.Silent || target.DisableNotification,
+		buttons: notification.Buttons,
+	}
+
+	key := s.entityKey(recipient, notification)
+	if key != "" {
+		if messageID, ok := s.entityMessage(ctx, key); ok {
+			switch s.entityMode {
+			case EntityModeEdit:
+				if messageLength(notification.Body) <= maxMessageLength {
+					editOpts := opts
+					editOpts.editMessageID = messageID
+					_, err := s.sendText(ctx, chatID, notification.Body, editOpts)
+					if err == nil || isNotModifiedError(err) {
+						return nil
+					}
+					log.FromContext(ctx).Warn().Err(err).Str("recipient", recipient.Name).Msg("failed to edit entity message, sending a new one")
+				}
+			case EntityModeReply:
+				opts.replyTo = messageID
+				_, err := s.deliver(ctx, chatID, notification, opts)
+				return err
+			}
+		}
+	}
 
-	err := s.trySend(ctx, chatID, escapedText, "MarkdownV2")
+	messageID, err := s.deliver(ctx, chatID, notification, opts)
+	if err != nil {
+		return err
+	}
+
+	if key != "" && messageID != 0 {
+		if err := ports.SetExpiring(ctx, s.store, key, strconv.Itoa(messageID)); err != nil {
+			log.FromContext(ctx).Warn().Err(err).Str("recipient", recipient.Name).Msg("failed to remember entity message")
+		}
+	}
+	return nil
+}
+
+// deliver sends the notification as one or more messages and returns the id of the first one
+func (s *Sender) deliver(ctx context.Context, chatID string, notification domain.Notification, opts messageOptions) (int, error) {
+	if messageLength(notification.Body) <= maxMessageLength {
+		return s.sendText(ctx, chatID, notification.Body, opts)
+	}
+
+	if s.longMessages == LongMessagesDocument {
+		return s.sendDocument(ctx, chatID, notification, opts)
+	}
+
+	firstID := 0
+	parts := splitMessage(notification.Body, maxMessageLength-partHeaderReserve)
+	for i, part := range parts {
+		partOpts := opts
+		if i < len(parts)-1 {
+			partOpts.buttons = nil // buttons go under the last part
+		}
+		text := fmt.Sprintf("%d/%d\n%s", i+1, len(parts), part)
+		messageID, err := s.sendText(ctx, chatID, text, partOpts)
+		if err != nil {
+			return firstID, fmt.Errorf("failed to send part %d/%d: %w", i+1, len(parts), err)
+		}
+		if i == 0 {
+			firstID = messageID
+		}
+	}
+	return firstID, nil
+}
+
+// sendText sends a MarkdownV2 message and falls back to plain text if Telegram cannot parse it
+func (s *Sender) sendText(ctx context.Context, chatID, text string, opts messageOptions) (int, error) {
+	escapedText := escapeMarkdownV2Selective(text)
+
+	messageID, err := s.trySend(ctx, chatID, escapedText, "MarkdownV2", opts)
 	if err == nil {
-		return nil
+		return messageID, nil
 	}
 
-	if strings.Contains(err.Error(), "can't parse entities") {
+	if isParseError(err) {
 		log.FromContext(ctx).Warn().Msg("MarkdownV2 parsing failed, falling back to plain text.")
-		return s.trySend(ctx, chatID, notification.Body, "")
+		return s.trySend(ctx, chatID, text, "", opts)
 	}
 
-	return err
+	return 0, err
 }
 
-func (s *Sender) trySend(ctx context.Context, chatID, text, parseMode string) error {
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.token)
-
-	payload := map[string]string{
+func (s *Sender) trySend(ctx context.Context, chatID, text, parseMode string, opts messageOptions) (int, error) {
+	method := "sendMessage"
+	payload := map[string]interface{}{
 		"chat_id": chatID,
 		"text":    text,
 	}
 	if parseMode != "" {
 		payload["parse_mode"] = parseMode
 	}
+	if opts.target.DisableWebPagePreview {
+		payload["link_preview_options"] = map[string]bool{"is_disabled": true}
+	}
+
+	if opts.editMessageID != 0 {
+		method = "editMessageText"
+		payload["message_id"] = opts.editMessageID
+		if markup := replyMarkup(opts.buttons); markup != nil {
+			payload["reply_markup"] = markup
+		}
+	} else {
+		for name, value := range opts.params() {
+			payload[name] = value
+		}
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal json payload: %w", err)
+		return 0, fmt.Errorf("failed to marshal json payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	var result message
+	if err := s.call(ctx, chatID, method, "application/json", jsonPayload, &result); err != nil {
+		return 0, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return result.MessageID, nil
+}
 
-	resp, err := s.client.Do(req)
+// params returns the parameters that sendMessage and sendDocument have in common
+func (o messageOptions) params() map[string]interface{} {
+	params := make(map[string]interface{})
+	if o.target.MessageThreadID != 0 {
+		params["message_thread_id"] = o.target.MessageThreadID
+	}
+	if o.silent {
+		params["disable_notification"] = true
+	}
+	if o.target.ProtectContent {
+		params["protect_content"] = true
+	}
+	if markup := replyMarkup(o.buttons); markup != nil {
+		params["reply_markup"] = markup
+	}
+	if o.replyTo != 0 {
+		params["reply_parameters"] = map[string]interface{}{
+			"message_id":                  o.replyTo,
+			"allow_sending_without_reply": true,
+		}
+	}
+	return params
+}
+
+// replyMarkup lays out URL buttons as an inline keyboard
+func replyMarkup(buttons []domain.Button) map[string]interface{} {
+	if len(buttons) == 0 {
+		return nil
+	}
+	var rows [][]map[string]string
+	for i, button := range buttons {
+		if i%maxButtonsPerRow == 0 {
+			rows = append(rows, nil)
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], map[string]string{
+			"text": button.Text,
+			"url":  button.URL,
+		})
+	}
+	return map[string]interface{}{"inline_keyboard": rows}
+}
+
+// sendDocument sends the whole notification as a text file with a short plain text caption
+func (s *Sender) sendDocument(ctx context.Context, chatID string, notification domain.Notification, opts messageOptions) (int, error) {
+	caption := notification.Title
+	if caption == "" {
+		caption, _, _ = strings.Cut(strings.TrimSpace(notification.Body), "\n")
+	}
+	if runes := []rune(caption); len(runes) > maxCaptionLength {
+		caption = string(runes[:maxCaptionLength-1]) + "…"
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := map[string]interface{}{
+		"chat_id": chatID,
+		"caption": caption,
+	}
+	for name, value := range opts.params() {
+		fields[name] = value
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, formValue(value)); err != nil {
+			return 0, fmt.Errorf("failed to write form field '%s': %w", name, err)
+		}
+	}
+	file, err := writer.CreateFormFile("document", documentFileName)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return 0, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.WriteString(file, notification.Body); err != nil {
+		return 0, fmt.Errorf("failed to write document: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish multipart body: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API error: status %d, response: %s", resp.StatusCode, string(body))
+	var result message
+	if err := s.call(ctx, chatID, "sendDocument", writer.FormDataContentType(), body.Bytes(), &result); err != nil {
+		return 0, err
 	}
+	return result.MessageID, nil
+}
 
-	return nil
+// formValue encodes a Bot API parameter for multipart requests, objects are passed as JSON
+func formValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
 }
 
+// Characters that Telegram requires to be escaped in MarkdownV2 mode.
+// We are intentionally NOT escaping *, _, `, [ and ] because we assume they are used for formatting.
+// The parentheses ( and ) are also not escaped here to allow for [link](url) syntax.
+// If you have literal parentheses to send, they must be escaped.
+const markdownV2Escaped = "~>#+-=|{}.!"
+
 // escapeMarkdownV2Selective selectively escapes characters for Telegram's MarkdownV2 parser.
 // It preserves common formatting characters like *, _, `, [ and ] to allow for intentional formatting.
 func escapeMarkdownV2Selective(text string) string {
-	// Characters that Telegram requires to be escaped in MarkdownV2 mode.
-	// We are intentionally NOT escaping *, _, `, [ and ] because we assume they are used for formatting.
-	// The parentheses ( and ) are also not escaped here to allow for [link](url) syntax.
-	// If you have literal parentheses to send, they must be escaped.
-	replacer := strings.NewReplacer(
-		"~", "\\~",
-		">", "\\>",
-		"#", "\\#",
-		"+", "\\+",
-		"-", "\\-",
-		"=", "\\=",
-		"|", "\\|",
-		"{", "\\{",
-		"}", "\\}",
-		".", "\\.",
-		"!", "\\!",
-	)
-	return replacer.Replace(text)
+	var escaped strings.Builder
+	escaped.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(markdownV2Escaped, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
 }
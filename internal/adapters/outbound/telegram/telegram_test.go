@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/storage"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// botAPI is a stand-in for the Bot API that records sendMessage calls
+type botAPI struct {
+	mu       sync.Mutex
+	messages []sentMessage
+	respond  func(w http.ResponseWriter, msg sentMessage) bool // handles the call itself if it returns true
+}
+
+type sentMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+	at     time.Time
+}
+
+func (b *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg sentMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg.at = time.Now()
+	if b.respond != nil && b.respond(w, msg) {
+		return
+	}
+
+	b.mu.Lock()
+	b.messages = append(b.messages, msg)
+	id := len(b.messages)
+	b.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": map[string]int{"message_id": id}})
+}
+
+func (b *botAPI) sent() []sentMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]sentMessage(nil), b.messages...)
+}
+
+func newTestSender(t *testing.T, api http.Handler) *Sender {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSender(Settings{
+		Token:        "1:test",
+		APIURL:       server.URL,
+		GlobalRate:   1000,
+		ChatInterval: time.Millisecond,
+	}, store, nil)
+}
+
+func recipient(chatID string) config.Recipient {
+	return config.Recipient{Name: "chat " + chatID, Target: chatID, Notifier: "telegram"}
+}
+
+// drain sends the queued messages and closes the sender
+func drain(t *testing.T, sender *Sender) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sender.Drain(ctx); err != nil {
+		t.Errorf("Drain() error = %v", err)
+	}
+	sender.Close()
+}
+
+func TestSendDoesNotWaitForDelivery(t *testing.T) {
+	release := make(chan struct{})
+	api := &botAPI{}
+	api.respond = func(w http.ResponseWriter, msg sentMessage) bool {
+		<-release
+		return false
+	}
+	sender := newTestSender(t, api)
+
+	start := time.Now()
+	if err := sender.Send(context.Background(), recipient("1"), domain.Notification{Body: "hello"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Send() took %s, want it to return before the message is sent", elapsed)
+	}
+
+	close(release)
+	drain(t, sender)
+	if sent := api.sent(); len(sent) != 1 || sent[0].Text != "hello" {
+		t.Errorf("sent %+v, want one message after Drain", sent)
+	}
+}
+
+func TestSendKeepsChatOrder(t *testing.T) {
+	api := &botAPI{}
+	sender := newTestSender(t, api)
+
+	bodies := []string{"one", "two", "three", "four", "five"}
+	for _, body := range bodies {
+		for _, chat := range []string{"1", "2"} {
+			if err := sender.Send(context.Background(), recipient(chat), domain.Notification{Body: body}); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+		}
+	}
+	drain(t, sender)
+
+	byChat := make(map[string][]string)
+	for _, msg := range api.sent() {
+		byChat[msg.ChatID] = append(byChat[msg.ChatID], msg.Text)
+	}
+	for _, chat := range []string{"1", "2"} {
+		got := byChat[chat]
+		if len(got) != len(bodies) {
+			t.Fatalf("chat %s got %v, want %v", chat, got, bodies)
+		}
+		for i := range bodies {
+			if got[i] != bodies[i] {
+				t.Errorf("chat %s got %v, want %v", chat, got, bodies)
+				break
+			}
+		}
+	}
+}
+
+func TestSendRejectsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	api := &botAPI{}
+	api.respond = func(w http.ResponseWriter, msg sentMessage) bool {
+		<-release
+		return false
+	}
+	sender := newTestSender(t, api)
+	sender.queue.size = 2
+	defer sender.Close()
+	defer close(release)
+
+	var failed int
+	for i := 0; i < 5; i++ {
+		if err := sender.Send(context.Background(), recipient("1"), domain.Notification{Body: "x"}); err != nil {
+			failed++
+		}
+	}
+	// the first message is taken by the worker, two wait, the rest are rejected
+	if failed < 2 {
+		t.Errorf("%d sends were rejected, want the ones over the queue size", failed)
+	}
+}
+
+func TestDrainStopsWithContext(t *testing.T) {
+	release := make(chan struct{})
+	api := &botAPI{}
+	api.respond = func(w http.ResponseWriter, msg sentMessage) bool {
+		<-release
+		return false
+	}
+	sender := newTestSender(t, api)
+	defer close(release)
+
+	for _, body := range []string{"first", "second"} {
+		if err := sender.Send(context.Background(), recipient("1"), domain.Notification{Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := sender.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() error = %v, want the deadline of ctx", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Drain() took %s, want it to stop with ctx", elapsed)
+	}
+
+	// Close cancels the message being sent and drops the waiting one
+	sender.Close()
+	if sent := api.sent(); len(sent) > 1 {
+		t.Errorf("sent %+v after Close, want the waiting message dropped", sent)
+	}
+	if err := sender.Send(context.Background(), recipient("1"), domain.Notification{Body: "late"}); err == nil {
+		t.Error("Send() after Close succeeded")
+	}
+}
+
+func TestRetryAfterDelaysEveryChat(t *testing.T) {
+	api := &botAPI{}
+	var once sync.Once
+	api.respond = func(w http.ResponseWriter, msg sentMessage) bool {
+		limited := false
+		once.Do(func() {
+			limited = true
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 1",
+				"parameters": map[string]int{"retry_after": 1},
+			})
+		})
+		return limited
+	}
+	sender := newTestSender(t, api)
+
+	start := time.Now()
+	if err := sender.Send(context.Background(), recipient("1"), domain.Notification{Body: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // let the first chat hit the limit
+	if err := sender.Send(context.Background(), recipient("2"), domain.Notification{Body: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, sender)
+
+	sent := api.sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sent))
+	}
+	for _, msg := range sent {
+		if wait := msg.at.Sub(start); wait < 900*time.Millisecond {
+			t.Errorf("message to chat %s sent after %s, want it to wait for retry_after", msg.ChatID, wait)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package registry maps the webhook and notifier types of the config to the adapters implementing them.
+// Adapter packages register themselves in init, so importing a package is enough to make its type available.
+package registry
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// Env holds what adapters may need besides their own settings
+type Env struct {
+	Config *config.Config
+	Store  ports.Store
+}
+
+// Webhook creates the handlers of a webhook type. S is its settings struct, decoded from the 'settings'
+// of the webhook; adapters without settings use struct{}.
+type Webhook[S any] struct {
+	Validate func(cfg config.WebhookConfig, settings S) error // optional, must not have side effects
+	New      func(cfg config.WebhookConfig, settings S, env Env) (ports.WebhookHandler, error)
+}
+
+// Notifier creates the notifiers of a type. S is its settings struct, decoded from the 'settings' of the notifier.
+type Notifier[S any] struct {
+	Validate func(settings S) error // optional, must not have side effects such as connecting to a server
+	// ValidateRecipient checks the target and the options of a recipient against valid settings, optional.
+	// Recipients of a type without it must not have options.
+	ValidateRecipient func(settings S, recipient config.Recipient) error
+	New               func(name config.NotifierName, settings S, env Env) (ports.Notifier, error)
+}
+
+type webhookFactory struct {
+	validate func(cfg config.WebhookConfig) error
+	create   func(cfg config.WebhookConfig, env Env) (ports.WebhookHandler, error)
+}
+
+type notifierFactory struct {
+	validate          func(cfg config.NotifierConfig) error
+	validateRecipient func(cfg config.NotifierConfig, recipient config.Recipient) error
+	create            func(cfg config.NotifierConfig, env Env) (ports.Notifier, error)
+}
+
+var (
+	mu        sync.RWMutex
+	webhooks  = make(map[config.WebhookType]webhookFactory)
+	notifiers = make(map[config.NotifierType]notifierFactory)
+)
+
+// RegisterWebhook makes a webhook type available, it panics if the type is registered already
+func RegisterWebhook[S any](typ config.WebhookType, w Webhook[S]) {
+	if w.New == nil {
+		panic(fmt.Sprintf("registry: webhook type '%s' has no constructor", typ))
+	}
+
+	settings := func(cfg config.WebhookConfig, strict bool) (S, error) {
+		var settings S
+		decode := cfg.DecodeSettings
+		if strict {
+			decode = cfg.DecodeSettingsStrict
+		}
+		if err := decode(&settings); err != nil {
+			return settings, fmt.Errorf("failed to decode %s settings for '%s': %w", typ, cfg.Name, err)
+		}
+		if w.Validate != nil {
+			if err := w.Validate(cfg, settings); err != nil {
+				return settings, fmt.Errorf("invalid %s webhook '%s': %w", typ, cfg.Name, err)
+			}
+		}
+		return settings, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := webhooks[typ]; ok {
+		panic(fmt.Sprintf("registry: webhook type '%s' registered twice", typ))
+	}
+	webhooks[typ] = webhookFactory{
+		validate: func(cfg config.WebhookConfig) error {
+			_, err := settings(cfg, true)
+			return err
+		},
+		create: func(cfg config.WebhookConfig, env Env) (ports.WebhookHandler, error) {
+			s, err := settings(cfg, false)
+			if err != nil {
+				return nil, err
+			}
+			handler, err := w.New(cfg, s, env)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s processor for '%s': %w", typ, cfg.Name, err)
+			}
+			return handler, nil
+		},
+	}
+}
+
+// RegisterNotifier makes a notifier type available, it panics if the type is registered already
+func RegisterNotifier[S any](typ config.NotifierType, n Notifier[S]) {
+	if n.New == nil {
+		panic(fmt.Sprintf("registry: notifier type '%s' has no constructor", typ))
+	}
+
+	settings := func(cfg config.NotifierConfig, strict bool) (S, error) {
+		var settings S
+		decode := cfg.DecodeSettings
+		if strict {
+			decode = cfg.DecodeSettingsStrict
+		}
+		if err := decode(&settings); err != nil {
+			return settings, fmt.Errorf("failed to decode %s settings for '%s': %w", typ, cfg.Name, err)
+		}
+		if n.Validate != nil {
+			if err := n.Validate(settings); err != nil {
+				return settings, fmt.Errorf("invalid %s settings for '%s': %w", typ, cfg.Name, err)
+			}
+		}
+		return settings, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := notifiers[typ]; ok {
+		panic(fmt.Sprintf("registry: notifier type '%s' registered twice", typ))
+	}
+	notifiers[typ] = notifierFactory{
+		validate: func(cfg config.NotifierConfig) error {
+			_, err := settings(cfg, true)
+			return err
+		},
+		validateRecipient: func(cfg config.NotifierConfig, recipient config.Recipient) error {
+			var err error
+			switch {
+			case n.ValidateRecipient != nil:
+				s, settingsErr := settings(cfg, false)
+				if settingsErr != nil {
+					// reported by validate, the recipient cannot be checked without the settings
+					return nil
+				}
+				err = n.ValidateRecipient(s, recipient)
+			case len(recipient.Options) > 0:
+				err = fmt.Errorf("unknown options, %s recipients have none", typ)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s recipient '%s': %w", typ, recipient.Name, err)
+			}
+			return nil
+		},
+		create: func(cfg config.NotifierConfig, env Env) (ports.Notifier, error) {
+			s, err := settings(cfg, false)
+			if err != nil {
+				return nil, err
+			}
+			notifier, err := n.New(cfg.Name, s, env)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s sender '%s': %w", typ, cfg.Name, err)
+			}
+			return notifier, nil
+		},
+	}
+}
+
+// NewWebhookHandler creates the handler of a webhook with the adapter registered for its type
+func NewWebhookHandler(cfg config.WebhookConfig, env Env) (ports.WebhookHandler, error) {
+	factory, err := webhookFactoryFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return factory.create(cfg, env)
+}
+
+// ValidateWebhook checks the type and the settings of a webhook without creating its handler.
+// Unlike NewWebhookHandler, it also fails on settings keys the adapter does not know.
+func ValidateWebhook(cfg config.WebhookConfig) error {
+	factory, err := webhookFactoryFor(cfg)
+	if err != nil {
+		return err
+	}
+	return factory.validate(cfg)
+}
+
+// NewNotifier creates a notifier with the adapter registered for its type
+func NewNotifier(cfg config.NotifierConfig, env Env) (ports.Notifier, error) {
+	factory, err := notifierFactoryFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return factory.create(cfg, env)
+}
+
+// ValidateNotifier checks the type and the settings of a notifier without creating it.
+// Unlike NewNotifier, it also fails on settings keys the adapter does not know.
+func ValidateNotifier(cfg config.NotifierConfig) error {
+	factory, err := notifierFactoryFor(cfg)
+	if err != nil {
+		return err
+	}
+	return factory.validate(cfg)
+}
+
+// ValidateRecipient checks the target and the options of a recipient of the notifier cfg
+func ValidateRecipient(cfg config.NotifierConfig, recipient config.Recipient) error {
+	factory, err := notifierFactoryFor(cfg)
+	if err != nil {
+		return err
+	}
+	return factory.validateRecipient(cfg, recipient)
+}
+
+// WebhookTypes returns the registered webhook types in alphabetical order
+func WebhookTypes() []config.WebhookType {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	types := make([]config.WebhookType, 0, len(webhooks))
+	for typ := range webhooks {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// NotifierTypes returns the registered notifier types in alphabetical order
+func NotifierTypes() []config.NotifierType {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	types := make([]config.NotifierType, 0, len(notifiers))
+	for typ := range notifiers {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	return types
+}
+
+func webhookFactoryFor(cfg config.WebhookConfig) (webhookFactory, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	factory, ok := webhooks[cfg.Type]
+	if !ok {
+		return webhookFactory{}, fmt.Errorf("unknown webhook handler type '%s' for '%s'", cfg.Type, cfg.Name)
+	}
+	return factory, nil
+}
+
+func notifierFactoryFor(cfg config.NotifierConfig) (notifierFactory, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	factory, ok := notifiers[cfg.Type]
+	if !ok {
+		return notifierFactory{}, fmt.Errorf("unknown sender type '%s' for '%s'", cfg.Type, cfg.Name)
+	}
+	return factory, nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+const (
+	DefaultRetention = 30 * 24 * time.Hour
+	// flushDelay batches the changes made in a burst of notifications into one write of the file
+	flushDelay = time.Second
+)
+
+var _ ports.ExpiringStore = (*Store)(nil)
+
+// Store is a key-value store kept in memory and mirrored to a JSON file.
+// Without a path the state lives in memory only and is lost on restart.
+// Changes are written shortly after they are made and on Close.
+type Store struct {
+	path      string
+	retention time.Duration
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time // of the values set with SetExpiring
+	dirty   bool
+	timer   *time.Timer // pending write of the file
+	err     error       // of the last background write, returned by the next call
+	closed  bool
+}
+
+// fileState is the content of the file. Files written by older versions hold the values only.
+type fileState struct {
+	Version int                  `json:"version"`
+	Values  map[string]string    `json:"values"`
+	Expires map[string]time.Time `json:"expires,omitempty"`
+}
+
+const fileVersion = 2
+
+// New opens the store at path. Values set with SetExpiring are removed once they have not been set
+// or read for retention, DefaultRetention if it is not positive.
+func New(path string, retention time.Duration) (*Store, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	s := &Store{
+		path:      path,
+		retention: retention,
+		values:    make(map[string]string),
+		expires:   make(map[string]time.Time),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store file: %w", err)
+	}
+	if len(data) > 0 {
+		if err := s.decode(data); err != nil {
+			return nil, fmt.Errorf("failed to decode store file '%s': %w", path, err)
+		}
+	}
+	s.prune(time.Now())
+	return s, nil
+}
+
+func (s *Store) decode(data []byte) error {
+	var state fileState
+	if err := json.Unmarshal(data, &state); err == nil && state.Version == fileVersion {
+		if state.Values != nil {
+			s.values = state.Values
+		}
+		if state.Expires != nil {
+			s.expires = state.Expires
+		}
+		return nil
+	}
+	return json.Unmarshal(data, &s.values)
+}
+
+func (s *Store) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := s.expires[key]; ok {
+		if !now.Before(expires) {
+			s.remove(key)
+			return "", false, s.takeErr()
+		}
+		// still in use, e.g. a PR that keeps getting updates
+		s.expires[key] = now.Add(s.retention)
+		s.changed()
+	}
+	value, ok := s.values[key]
+	return value, ok, s.takeErr()
+}
+
+func (s *Store) Set(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	delete(s.expires, key)
+	s.changed()
+	return s.takeErr()
+}
+
+func (s *Store) SetExpiring(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	s.expires[key] = time.Now().Add(s.retention)
+	s.changed()
+	return s.takeErr()
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[key]; !ok {
+		return nil
+	}
+	s.remove(key)
+	return s.takeErr()
+}
+
+// Close writes the pending changes. The store must not be used afterwards.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if err := s.takeErr(); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *Store) remove(key string) {
+	delete(s.values, key)
+	delete(s.expires, key)
+	s.changed()
+}
+
+// prune removes the expired values
+func (s *Store) prune(now time.Time) {
+	for key, expires := range s.expires {
+		if !now.Before(expires) {
+			s.remove(key)
+		}
+	}
+}
+
+// changed schedules a write of the file, unless one is pending already
+func (s *Store) changed() {
+	s.dirty = true
+	if s.path == "" || s.timer != nil || s.closed {
+		return
+	}
+	s.timer = time.AfterFunc(flushDelay, s.flush)
+}
+
+func (s *Store) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timer = nil
+	if s.closed {
+		return
+	}
+	s.prune(time.Now())
+	if err := s.save(); err != nil {
+		s.err = err
+	}
+}
+
+func (s *Store) takeErr() error {
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// save writes the whole state to a temporary file and renames it over the old one
+func (s *Store) save() error {
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(fileState{Version: fileVersion, Values: s.values, Expires: s.expires}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace store file: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreExpiresEntityState(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := New(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, "chat", "settings"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetExpiring(ctx, "pr", "message"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Get(ctx, "pr"); !ok {
+		t.Fatal("expiring value is missing before its retention")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "pr"); ok {
+		t.Error("expiring value is kept after its retention")
+	}
+	if value, ok, _ := store.Get(ctx, "chat"); !ok || value != "settings" {
+		t.Errorf("value = %q, %v, want the value set without expiry", value, ok)
+	}
+}
+
+func TestStoreDebouncesWrites(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := New(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.SetExpiring(ctx, key, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file is written on every change: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := New(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if value, ok, _ := reopened.Get(ctx, key); !ok || value != key {
+			t.Errorf("Get(%q) = %q, %v after reopening", key, value, ok)
+		}
+	}
+}
+
+func TestStoreLoadsLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"telegram:pr":"42"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := New(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := store.Get(context.Background(), "telegram:pr"); !ok || value != "42" {
+		t.Errorf("value = %q, %v, want the value of the legacy file", value, ok)
+	}
+}
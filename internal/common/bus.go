@@ -0,0 +1,95 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// BusMessage is the JSON document the message bus notifiers publish: the normalized event
+// along with the notification rendered for the recipient
+type BusMessage struct {
+	ID           string          `json:"id"`
+	Time         time.Time       `json:"time"`
+	Recipient    string          `json:"recipient"`
+	Event        BusEvent        `json:"event"`
+	Notification BusNotification `json:"notification"`
+}
+
+type BusEvent struct {
+	Webhook  string          `json:"webhook"`
+	Name     string          `json:"name,omitempty"`
+	Severity domain.Severity `json:"severity,omitempty"`
+	Entity   string          `json:"entity,omitempty"`
+	Payload  interface{}     `json:"payload,omitempty"`
+}
+
+type BusNotification struct {
+	Title     string      `json:"title"`
+	Body      string      `json:"body"`
+	Text      string      `json:"text"` // the body without Markdown
+	ParseMode string      `json:"parse_mode,omitempty"`
+	Silent    bool        `json:"silent,omitempty"`
+	Buttons   []BusButton `json:"buttons,omitempty"`
+}
+
+type BusButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// NewBusMessage builds the message for a recipient with a random id consumers can deduplicate by
+func NewBusMessage(recipient config.Recipient, notification domain.Notification) BusMessage {
+	buttons := make([]BusButton, 0, len(notification.Buttons))
+	for _, button := range notification.Buttons {
+		buttons = append(buttons, BusButton{Text: button.Text, URL: button.URL})
+	}
+
+	return BusMessage{
+		ID:        newMessageID(),
+		Time:      time.Now().UTC(),
+		Recipient: recipient.Name,
+		Event: BusEvent{
+			Webhook:  notification.Webhook,
+			Name:     notification.Event,
+			Severity: notification.Severity,
+			Entity:   notification.EntityKey,
+			Payload:  notification.Payload,
+		},
+		Notification: BusNotification{
+			Title:     PlainTitle(notification),
+			Body:      notification.Body,
+			Text:      MarkdownToText(notification.Body),
+			ParseMode: notification.ParseMode,
+			Silent:    notification.Silent,
+			Buttons:   buttons,
+		},
+	}
+}
+
+func (m BusMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// BusTopic resolves the subject, stream or routing key of a message: the recipient target,
+// or the pattern from the settings. '{webhook}' and '{event}' are replaced in both.
+func BusTopic(pattern string, recipient config.Recipient, notification domain.Notification) string {
+	if recipient.Target != "" {
+		pattern = recipient.Target
+	}
+	return strings.NewReplacer(
+		"{webhook}", notification.Webhook,
+		"{event}", notification.Event,
+	).Replace(pattern)
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// Buttons are emitted by templates as markers made of private use characters and are
+// moved out of the message text after rendering
+const (
+	buttonMarker    = "\uE000"
+	buttonSeparator = "\uE001"
+)
+
+var (
+	buttonPattern = regexp.MustCompile(buttonMarker + "button" + buttonSeparator + "([^" + buttonSeparator + "]*)" + buttonSeparator + "([^" + buttonMarker + "]*)" + buttonMarker)
+	// a marker with the spaces separating it from the text before it
+	buttonGapPattern = regexp.MustCompile(`[ \t]*` + buttonPattern.String())
+)
+
+// button declares a URL button attached to the notification.
+// Usage: {{ button "Open PR" .pull_request.html_url }}
+func button(text string, url interface{}) string {
+	link := toString(url)
+	if link == "" {
+		return ""
+	}
+	return fmt.Sprintf("%sbutton%s%s%s%s%s", buttonMarker, buttonSeparator, text, buttonSeparator, link, buttonMarker)
+}
+
+// extractButtons removes button markers from the rendered text and returns them in order
+func extractButtons(text string) (string, []domain.Button) {
+	matches := buttonPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	buttons := make([]domain.Button, 0, len(matches))
+	for _, match := range matches {
+		buttons = append(buttons, domain.Button{Text: match[1], URL: match[2]})
+	}
+
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	skipBlank := false
+	for _, line := range lines {
+		if !buttonPattern.MatchString(line) {
+			if !(skipBlank && strings.TrimSpace(line) == "") {
+				kept = append(kept, line)
+			}
+			skipBlank = false
+			continue
+		}
+
+		if line = buttonGapPattern.ReplaceAllString(line, ""); strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+			skipBlank = false
+			continue
+		}
+		// the line only held buttons: drop it, and the blank line after it if another one separates it already
+		skipBlank = len(kept) == 0 || strings.TrimSpace(kept[len(kept)-1]) == ""
+	}
+	return strings.Join(kept, "\n"), buttons
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestExtractButtons(t *testing.T) {
+	pr := button("Open PR", "https://example.com/pr/1")
+	ci := button("CI", "https://example.com/ci")
+	tests := []struct {
+		name        string
+		text        string
+		want        string
+		wantButtons int
+	}{
+		{
+			name: "no buttons keeps the text as is",
+			text: "title\n\n\nbody\n\n",
+			want: "title\n\n\nbody\n\n",
+		},
+		{
+			name:        "button line at the end",
+			text:        "title\n\nbody\n\n" + pr + "\n",
+			want:        "title\n\nbody\n",
+			wantButtons: 1,
+		},
+		{
+			name:        "button line between paragraphs",
+			text:        "title\n\n" + pr + " " + ci + "\n\nbody\n",
+			want:        "title\n\nbody\n",
+			wantButtons: 2,
+		},
+		{
+			name:        "inline button",
+			text:        "see the PR " + pr + " for details\n",
+			want:        "see the PR for details\n",
+			wantButtons: 1,
+		},
+		{
+			name:        "unrelated blank lines kept",
+			text:        "title\n\n\nbody\n\n" + pr + "\n",
+			want:        "title\n\n\nbody\n",
+			wantButtons: 1,
+		},
+		{
+			name:        "button line at the start",
+			text:        pr + "\n\ntitle\n",
+			want:        "title\n",
+			wantButtons: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, buttons := extractButtons(tt.text)
+			if got != tt.want {
+				t.Errorf("extractButtons() text = %q, want %q", got, tt.want)
+			}
+			if len(buttons) != tt.wantButtons {
+				t.Errorf("extractButtons() got %d buttons, want %d", len(buttons), tt.wantButtons)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package common
+
+import "github.com/shanth1/hookrelay/internal/core/domain"
+
+// SeverityColor returns the accent color of message attachments for a severity
+func SeverityColor(severity domain.Severity) string {
+	switch {
+	case severity.AtLeast(domain.SeverityCritical):
+		return "#D0021B"
+	case severity.AtLeast(domain.SeverityWarning):
+		return "#F5A623"
+	default:
+		return "#2F6FEB"
+	}
+}
@@ -0,0 +1,235 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const shortSHALength = 7
+
+// TemplateFuncs returns the helper functions available in the templates of all inbound adapters.
+// Locale dependent functions format for the default locale, see LocaleFuncs.
+func TemplateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"truncate":       truncate,
+		"firstLine":      firstLine,
+		"shortSHA":       shortSHA,
+		"formatTime":     formatTime,
+		"escapeMarkdown": escapeMarkdown,
+		"escapeHTML":     escapeHTML,
+		"default":        defaultValue,
+		"join":           join,
+		"toJSON":         toJSON,
+		"dig":            dig,
+		"button":         button,
+	}
+	for name, fn := range LocaleFuncs(DefaultLocale) {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// truncate shortens s to at most length runes, marking the cut with an ellipsis.
+// Usage: {{ .comment.body | truncate 200 }}
+func truncate(length int, value interface{}) string {
+	s := toString(value)
+	runes := []rune(s)
+	if length <= 0 || len(runes) <= length {
+		return s
+	}
+	if length == 1 {
+		return "…"
+	}
+	return strings.TrimRight(string(runes[:length-1]), " \t\n") + "…"
+}
+
+// firstLine returns the first non-empty line of s, e.g. the subject of a commit message
+func firstLine(value interface{}) string {
+	for _, line := range strings.Split(toString(value), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func shortSHA(value interface{}) string {
+	sha := toString(value)
+	if len(sha) <= shortSHALength {
+		return sha
+	}
+	return sha[:shortSHALength]
+}
+
+// toString renders payload values as text, treating missing values as empty
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatTime formats a moment with a Go layout in the given IANA timezone.
+// Usage: {{ .head_commit.timestamp | formatTime "2006-01-02 15:04" "Europe/Berlin" }}
+func formatTime(layout, timezone string, value interface{}) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown timezone '%s': %w", timezone, err)
+		}
+		t = t.In(loc)
+	}
+	return t.Format(layout), nil
+}
+
+// toTime accepts time values, RFC 3339 strings and unix timestamps as they appear in webhook payloads
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(seconds), nil
+		}
+		return time.Time{}, fmt.Errorf("cannot parse time '%s'", v)
+	case float64:
+		return unixTime(v), nil
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case json.Number:
+		seconds, err := v.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse time '%s': %w", v, err)
+		}
+		return unixTime(seconds), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value of type %T", value)
+	}
+}
+
+func unixTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// escapeMarkdown escapes the formatting characters that the Telegram notifier deliberately leaves untouched,
+// so user content such as titles and comments cannot break the message markup
+func escapeMarkdown(value interface{}) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		"_", "\\_",
+		"*", "\\*",
+		"`", "\\`",
+		"[", "\\[",
+		"]", "\\]",
+		"(", "\\(",
+		")", "\\)",
+	)
+	return replacer.Replace(toString(value))
+}
+
+func escapeHTML(value interface{}) string {
+	return html.EscapeString(toString(value))
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case json.Number:
+		return v.Float64()
+	default:
+		return 0, fmt.Errorf("unsupported number of type %T", value)
+	}
+}
+
+// defaultValue returns fallback when value is missing or empty.
+// Usage: {{ .issue.assignee.login | default "nobody" }}
+func defaultValue(fallback, value interface{}) interface{} {
+	if isEmpty(value) {
+		return fallback
+	}
+	return value
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// join concatenates the elements of a list with a separator.
+// Usage: {{ .labels | join ", " }}
+func join(sep string, list interface{}) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: unsupported list of type %T", list)
+	}
+	items := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		items = append(items, fmt.Sprint(v.Index(i).Interface()))
+	}
+	return strings.Join(items, sep), nil
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("toJSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// dig walks nested maps and returns nil instead of failing when a key is missing.
+// Usage: {{ dig . "pull_request" "head" "repo" "full_name" }}
+func dig(value interface{}, keys ...string) interface{} {
+	current := value
+	for _, key := range keys {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[key]; !ok {
+			return nil
+		}
+	}
+	return current
+}
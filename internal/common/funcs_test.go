@@ -0,0 +1,368 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		value  interface{}
+		want   string
+	}{
+		{name: "shorter than limit", length: 10, value: "hello", want: "hello"},
+		{name: "exact limit", length: 5, value: "hello", want: "hello"},
+		{name: "cut with ellipsis", length: 4, value: "hello", want: "hel…"},
+		{name: "trailing space trimmed before ellipsis", length: 7, value: "hello world", want: "hello…"},
+		{name: "multibyte runes", length: 4, value: "привет", want: "при…"},
+		{name: "emoji", length: 2, value: "🚀🚀🚀", want: "🚀…"},
+		{name: "length one", length: 1, value: "hello", want: "…"},
+		{name: "zero length keeps value", length: 0, value: "hello", want: "hello"},
+		{name: "nil", length: 3, value: nil, want: ""},
+		{name: "number", length: 3, value: 123456, want: "12…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.length, tt.value); got != tt.want {
+				t.Errorf("truncate(%d, %v) = %q, want %q", tt.length, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "single line", value: "fix bug", want: "fix bug"},
+		{name: "commit message", value: "fix bug\n\nlong description", want: "fix bug"},
+		{name: "leading blank lines", value: "\n  \n  subject  \nbody", want: "subject"},
+		{name: "empty", value: "", want: ""},
+		{name: "nil", value: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstLine(tt.value); got != tt.want {
+				t.Errorf("firstLine(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortSHA(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "0123456789abcdef", want: "0123456"},
+		{value: "0123456", want: "0123456"},
+		{value: "abc", want: "abc"},
+		{value: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := shortSHA(tt.value); got != tt.want {
+			t.Errorf("shortSHA(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		timezone string
+		value    interface{}
+		want     string
+		wantErr  bool
+	}{
+		{name: "RFC 3339 in UTC", layout: "2006-01-02 15:04", value: "2024-03-01T10:30:00Z", want: "2024-03-01 10:30"},
+		{name: "RFC 3339 with offset", layout: "15:04 MST", timezone: "UTC", value: "2024-03-01T10:30:00+02:00", want: "08:30 UTC"},
+		{name: "timezone", layout: "2006-01-02 15:04", timezone: "Europe/Berlin", value: "2024-03-01T10:30:00Z", want: "2024-03-01 11:30"},
+		{name: "unix seconds as float", layout: time.RFC3339, timezone: "UTC", value: float64(1700000000), want: "2023-11-14T22:13:20Z"},
+		{name: "unix seconds as string", layout: time.RFC3339, timezone: "UTC", value: "1700000000", want: "2023-11-14T22:13:20Z"},
+		{name: "unix seconds as int", layout: time.RFC3339, timezone: "UTC", value: 1700000000, want: "2023-11-14T22:13:20Z"},
+		{name: "json number", layout: time.RFC3339, timezone: "UTC", value: json.Number("1700000000"), want: "2023-11-14T22:13:20Z"},
+		{name: "time value", layout: "2006-01-02", value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), want: "2024-01-02"},
+		{name: "unknown timezone", layout: time.RFC3339, timezone: "Mars/Olympus", value: "2024-03-01T10:30:00Z", wantErr: true},
+		{name: "invalid string", layout: time.RFC3339, value: "yesterday", wantErr: true},
+		{name: "unsupported type", layout: time.RFC3339, value: []int{1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatTime(tt.layout, tt.timezone, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "plain text", want: "plain text"},
+		{value: "snake_case *bold*", want: `snake\_case \*bold\*`},
+		{value: "[link](url)", want: `\[link\]\(url\)`},
+		{value: "`code`", want: "\\`code\\`"},
+		{value: `back\slash`, want: `back\\slash`},
+		{value: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := escapeMarkdown(tt.value); got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "<b>bold</b>", want: "&lt;b&gt;bold&lt;/b&gt;"},
+		{value: `"a" & 'b'`, want: "&#34;a&#34; &amp; &#39;b&#39;"},
+		{value: "plain", want: "plain"},
+		{value: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := escapeHTML(tt.value); got != tt.want {
+			t.Errorf("escapeHTML(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	var nilMap map[string]interface{}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "nil", value: nil, want: "fallback"},
+		{name: "empty string", value: "", want: "fallback"},
+		{name: "empty slice", value: []interface{}{}, want: "fallback"},
+		{name: "nil map", value: nilMap, want: "fallback"},
+		{name: "zero number", value: 0, want: "fallback"},
+		{name: "false", value: false, want: "fallback"},
+		{name: "string", value: "octocat", want: "octocat"},
+		{name: "number", value: 42, want: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultValue("fallback", tt.value); got != tt.want {
+				t.Errorf("defaultValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "strings", list: []string{"bug", "ui"}, want: "bug, ui"},
+		{name: "payload list", list: []interface{}{"a", 1.5, true}, want: "a, 1.5, true"},
+		{name: "array", list: [2]int{1, 2}, want: "1, 2"},
+		{name: "empty", list: []string{}, want: ""},
+		{name: "nil", list: nil, want: ""},
+		{name: "not a list", list: "bug", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := join(", ", tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("join() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("join() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "map", value: map[string]interface{}{"b": 1, "a": "x"}, want: `{"a":"x","b":1}`},
+		{name: "list", value: []string{"a", "b"}, want: `["a","b"]`},
+		{name: "nil", value: nil, want: "null"},
+		{name: "unsupported", value: make(chan int), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toJSON(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("toJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDig(t *testing.T) {
+	payload := map[string]interface{}{
+		"pull_request": map[string]interface{}{
+			"head": map[string]interface{}{
+				"repo": map[string]interface{}{"full_name": "octo/repo"},
+			},
+			"title": "Fix",
+		},
+	}
+	tests := []struct {
+		name string
+		keys []string
+		want interface{}
+	}{
+		{name: "nested value", keys: []string{"pull_request", "head", "repo", "full_name"}, want: "octo/repo"},
+		{name: "one level", keys: []string{"pull_request", "title"}, want: "Fix"},
+		{name: "missing key", keys: []string{"pull_request", "base", "repo"}, want: nil},
+		{name: "through a string", keys: []string{"pull_request", "title", "length"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dig(payload, tt.keys...); got != tt.want {
+				t.Errorf("dig(%v) = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+	if got := dig(nil, "a"); got != nil {
+		t.Errorf("dig(nil) = %v, want nil", got)
+	}
+}
+
+func TestButton(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		url       interface{}
+		wantEmpty bool
+	}{
+		{name: "link", text: "Open PR", url: "https://github.com/octo/repo/pull/1"},
+		{name: "missing url", text: "Open PR", url: nil, wantEmpty: true},
+		{name: "empty url", text: "Open PR", url: "", wantEmpty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marker := button(tt.text, tt.url)
+			if tt.wantEmpty {
+				if marker != "" {
+					t.Errorf("button() = %q, want empty", marker)
+				}
+				return
+			}
+			text, buttons := extractButtons("before " + marker + " after\n")
+			if len(buttons) != 1 || buttons[0].Text != tt.text || buttons[0].URL != tt.url {
+				t.Errorf("extractButtons() buttons = %+v, want one with %q and %v", buttons, tt.text, tt.url)
+			}
+			if strings.Contains(text, buttonMarker) {
+				t.Errorf("extractButtons() left a marker in %q", text)
+			}
+		})
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		locale string
+		count  interface{}
+		forms  []string
+		want   string
+	}{
+		{locale: "en", count: 1, forms: []string{"commit", "commits"}, want: "commit"},
+		{locale: "en", count: 0, forms: []string{"commit", "commits"}, want: "commits"},
+		{locale: "en", count: 21, forms: []string{"commit", "commits"}, want: "commits"},
+		{locale: "ru", count: 1, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммит"},
+		{locale: "ru", count: 2, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммита"},
+		{locale: "ru", count: 4, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммита"},
+		{locale: "ru", count: 5, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 11, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 12, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 14, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 21, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммит"},
+		{locale: "ru", count: 22, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммита"},
+		{locale: "ru", count: 25, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 111, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 101, forms: []string{"коммит", "коммита", "коммитов"}, want: "коммит"},
+		{locale: "ru", count: "3", forms: []string{"коммит", "коммита", "коммитов"}, want: "коммита"},
+		{locale: "ru", count: 5, forms: []string{"коммит", "коммита"}, want: "коммита"},
+		{locale: "ru", count: "many", forms: []string{"коммит", "коммита", "коммитов"}, want: "коммитов"},
+		{locale: "ru", count: 1, forms: nil, want: ""},
+	}
+	for _, tt := range tests {
+		pluralize := LocaleFuncs(tt.locale)["pluralize"].(func(interface{}, ...string) string)
+		if got := pluralize(tt.count, tt.forms...); got != tt.want {
+			t.Errorf("pluralize[%s](%v, %v) = %q, want %q", tt.locale, tt.count, tt.forms, got, tt.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		locale string
+		value  interface{}
+		want   string
+	}{
+		{locale: "en", value: 1234567, want: "1,234,567"},
+		{locale: "en", value: 123, want: "123"},
+		{locale: "en", value: 1234.5, want: "1,234.5"},
+		{locale: "en", value: -1234, want: "-1,234"},
+		{locale: "en", value: "98765", want: "98,765"},
+		{locale: "en", value: json.Number("1000"), want: "1,000"},
+		{locale: "en", value: "n/a", want: "n/a"},
+		{locale: "ru", value: 1234567, want: "1\u00a0234\u00a0567"},
+		{locale: "ru", value: 1234.5, want: "1\u00a0234,5"},
+		{locale: "ru-RU", value: 1000, want: "1\u00a0000"},
+	}
+	for _, tt := range tests {
+		formatNumber := LocaleFuncs(tt.locale)["formatNumber"].(func(interface{}) string)
+		if got := formatNumber(tt.value); got != tt.want {
+			t.Errorf("formatNumber[%s](%v) = %q, want %q", tt.locale, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	tests := []struct {
+		locale   string
+		timezone string
+		value    interface{}
+		want     string
+		wantErr  bool
+	}{
+		{locale: "en", timezone: "UTC", value: "2024-03-01T10:30:00Z", want: "Mar 1, 2024 10:30 UTC"},
+		{locale: "ru", timezone: "Europe/Moscow", value: "2024-03-01T10:30:00Z", want: "01.03.2024 13:30 MSK"},
+		{locale: "de", timezone: "UTC", value: "2024-03-01T10:30:00Z", want: "Mar 1, 2024 10:30 UTC"},
+		{locale: "ru", timezone: "UTC", value: "not a date", wantErr: true},
+	}
+	for _, tt := range tests {
+		formatDate := LocaleFuncs(tt.locale)["formatDate"].(func(string, interface{}) (string, error))
+		got, err := formatDate(tt.timezone, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("formatDate[%s](%v) error = %v, wantErr %v", tt.locale, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("formatDate[%s](%v) = %q, want %q", tt.locale, tt.value, got, tt.want)
+		}
+	}
+}
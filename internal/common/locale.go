@@ -0,0 +1,234 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const DefaultLocale = "en"
+
+// localePattern matches language tags such as 'en', 'ru' or 'pt-BR'
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// localeFormat describes how dates, numbers and plurals are written in a language
+type localeFormat struct {
+	dateLayout  string
+	decimalSep  string
+	groupSep    string
+	pluralForm  func(n int64) int // index of the plural form for a count
+	justNow     string
+	ago         string // format for past moments, e.g. "%s ago"
+	fromNow     string // format for future moments, e.g. "in %s"
+	minuteForms []string
+	hourForms   []string
+	dayForms    []string
+	monthForms  []string
+	yearForms   []string
+}
+
+var localeFormats = map[string]*localeFormat{
+	"en": {
+		dateLayout:  "Jan 2, 2006 15:04 MST",
+		decimalSep:  ".",
+		groupSep:    ",",
+		pluralForm:  englishPluralForm,
+		justNow:     "just now",
+		ago:         "%s ago",
+		fromNow:     "in %s",
+		minuteForms: []string{"minute", "minutes"},
+		hourForms:   []string{"hour", "hours"},
+		dayForms:    []string{"day", "days"},
+		monthForms:  []string{"month", "months"},
+		yearForms:   []string{"year", "years"},
+	},
+	"ru": {
+		dateLayout:  "02.01.2006 15:04 MST",
+		decimalSep:  ",",
+		groupSep:    " ",
+		pluralForm:  russianPluralForm,
+		justNow:     "только что",
+		ago:         "%s назад",
+		fromNow:     "через %s",
+		minuteForms: []string{"минуту", "минуты", "минут"},
+		hourForms:   []string{"час", "часа", "часов"},
+		dayForms:    []string{"день", "дня", "дней"},
+		monthForms:  []string{"месяц", "месяца", "месяцев"},
+		yearForms:   []string{"год", "года", "лет"},
+	},
+}
+
+// englishPluralForm selects between "one" and "other"
+func englishPluralForm(n int64) int {
+	if n == 1 || n == -1 {
+		return 0
+	}
+	return 1
+}
+
+// russianPluralForm selects between "one" (1, 21), "few" (2-4, 22-24) and "many" (5-20, 25) forms
+func russianPluralForm(n int64) int {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// GetLocaleChain returns the locale and its language fallbacks from the most to the least specific,
+// e.g. "pt-BR" gives ["pt-br", "pt"]
+func GetLocaleChain(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if locale == "" {
+		return nil
+	}
+	chain := []string{locale}
+	if lang, _, found := strings.Cut(locale, "-"); found && lang != "" {
+		chain = append(chain, lang)
+	}
+	return chain
+}
+
+// SupportedLocales returns the locales with dedicated date, number and plural formatting
+func SupportedLocales() []string {
+	locales := make([]string, 0, len(localeFormats))
+	for locale := range localeFormats {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// ValidateLocale checks that a recipient locale is a language tag such as 'en' or 'pt-BR'
+func ValidateLocale(locale string) error {
+	if !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid locale '%s', expected a language tag such as 'en' or 'pt-BR'", locale)
+	}
+	return nil
+}
+
+// IsSupportedLocale reports whether the locale or its language has dedicated formatting and built-in templates
+func IsSupportedLocale(locale string) bool {
+	for _, candidate := range GetLocaleChain(locale) {
+		if _, ok := localeFormats[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveLocale maps a recipient locale to a supported one, falling back to DefaultLocale
+func ResolveLocale(locale string) string {
+	for _, candidate := range GetLocaleChain(locale) {
+		if _, ok := localeFormats[candidate]; ok {
+			return candidate
+		}
+	}
+	return DefaultLocale
+}
+
+// LocaleFuncs returns the template functions whose output depends on the locale
+func LocaleFuncs(locale string) template.FuncMap {
+	lf := localeFormats[ResolveLocale(locale)]
+	return template.FuncMap{
+		"humanizeTime": lf.humanizeTime,
+		"formatDate":   lf.formatDate,
+		"formatNumber": lf.formatNumber,
+		"pluralize":    lf.pluralize,
+	}
+}
+
+// humanizeTime describes a moment relative to now, e.g. "5 minutes ago" or "in 2 hours"
+func (lf *localeFormat) humanizeTime(value interface{}) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return lf.humanizeDuration(time.Since(t)), nil
+}
+
+func (lf *localeFormat) humanizeDuration(d time.Duration) string {
+	format := lf.ago
+	if d < 0 {
+		d, format = -d, lf.fromNow
+	}
+
+	var amount int64
+	var forms []string
+	switch {
+	case d < time.Minute:
+		return lf.justNow
+	case d < time.Hour:
+		amount, forms = int64(d/time.Minute), lf.minuteForms
+	case d < 24*time.Hour:
+		amount, forms = int64(d/time.Hour), lf.hourForms
+	case d < 30*24*time.Hour:
+		amount, forms = int64(d/(24*time.Hour)), lf.dayForms
+	case d < 365*24*time.Hour:
+		amount, forms = int64(d/(30*24*time.Hour)), lf.monthForms
+	default:
+		amount, forms = int64(d/(365*24*time.Hour)), lf.yearForms
+	}
+	return fmt.Sprintf(format, fmt.Sprintf("%d %s", amount, lf.pluralize(amount, forms...)))
+}
+
+// formatDate formats a moment with the locale date layout in the given IANA timezone.
+// Usage: {{ .created_at | formatDate "Europe/Moscow" }}
+func (lf *localeFormat) formatDate(timezone string, value interface{}) (string, error) {
+	return formatTime(lf.dateLayout, timezone, value)
+}
+
+// formatNumber writes a number with the locale digit grouping and decimal separator.
+// Values that are not numbers are written as is.
+// Usage: {{ .repository.stargazers_count | formatNumber }}
+func (lf *localeFormat) formatNumber(value interface{}) string {
+	n, err := toFloat(value)
+	if err != nil {
+		return toString(value)
+	}
+
+	text := strconv.FormatFloat(math.Abs(n), 'f', -1, 64)
+	whole, frac, _ := strings.Cut(text, ".")
+
+	var grouped strings.Builder
+	if n < 0 {
+		grouped.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(lf.groupSep)
+		}
+		grouped.WriteRune(digit)
+	}
+	if frac != "" {
+		grouped.WriteString(lf.decimalSep)
+		grouped.WriteString(frac)
+	}
+	return grouped.String()
+}
+
+// pluralize picks the plural form for a count. English takes two forms, Russian takes three.
+// Usage: {{ len .commits }} {{ pluralize (len .commits) "commit" "commits" }}
+func (lf *localeFormat) pluralize(count interface{}, forms ...string) string {
+	if len(forms) == 0 {
+		return ""
+	}
+	n, err := toFloat(count)
+	if err != nil {
+		return forms[len(forms)-1]
+	}
+	index := lf.pluralForm(int64(n))
+	if index >= len(forms) {
+		index = len(forms) - 1
+	}
+	return forms[index]
+}
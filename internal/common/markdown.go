@@ -0,0 +1,162 @@
+package common
+
+import (
+	"html"
+	"strings"
+)
+
+// The templates are written in the Markdown dialect of Telegram: *bold*, _italic_, `code`,
+// [text](url), '> ' quotes and backslash escapes. MarkdownToHTML and MarkdownToText convert it
+// for channels such as email and Matrix, MarkdownToDialect for chats with another Markdown flavor.
+
+// MarkdownDialect describes the inline Markdown of a chat platform
+type MarkdownDialect struct {
+	Bold    string // e.g. '**' for CommonMark
+	Italic  string
+	Escapes bool // backslash escapes are understood, otherwise they are dropped
+}
+
+// inlineFormat renders the inline elements of a line
+type inlineFormat struct {
+	text   func(s string) string
+	code   func(s string) string
+	bold   func(inner string) string
+	italic func(inner string) string
+	link   func(label, url string) string
+	escape func(c string) string
+}
+
+var htmlFormat = inlineFormat{
+	text:   html.EscapeString,
+	code:   func(s string) string { return "<code>" + html.EscapeString(s) + "</code>" },
+	bold:   func(inner string) string { return "<b>" + inner + "</b>" },
+	italic: func(inner string) string { return "<i>" + inner + "</i>" },
+	link: func(label, url string) string {
+		return `<a href="` + html.EscapeString(url) + `">` + label + "</a>"
+	},
+	escape: html.EscapeString,
+}
+
+var textFormat = inlineFormat{
+	text:   func(s string) string { return s },
+	code:   func(s string) string { return s },
+	bold:   func(inner string) string { return inner },
+	italic: func(inner string) string { return inner },
+	link:   func(label, url string) string { return label + " (" + url + ")" },
+	escape: func(c string) string { return c },
+}
+
+// MarkdownToHTML converts a notification body to an HTML fragment
+func MarkdownToHTML(body string) string {
+	var out strings.Builder
+	inQuote := false
+	for i, line := range strings.Split(body, "\n") {
+		quote, isQuote := strings.CutPrefix(line, ">")
+		if isQuote != inQuote {
+			if isQuote {
+				out.WriteString(`<blockquote style="margin:0 0 0 8px;padding-left:8px;border-left:3px solid #ccc;color:#555">`)
+			} else {
+				out.WriteString("</blockquote>")
+			}
+			inQuote = isQuote
+		} else if i > 0 {
+			out.WriteString("<br>\n")
+		}
+		if isQuote {
+			line = strings.TrimPrefix(quote, " ")
+		}
+		out.WriteString(renderInline(line, htmlFormat))
+	}
+	if inQuote {
+		out.WriteString("</blockquote>")
+	}
+	return out.String()
+}
+
+// MarkdownToText strips the formatting of a notification body and spells out links
+func MarkdownToText(body string) string {
+	return renderLines(body, textFormat)
+}
+
+// MarkdownToDialect rewrites the formatting of a notification body for another Markdown dialect
+func MarkdownToDialect(body string, dialect MarkdownDialect) string {
+	return renderLines(body, inlineFormat{
+		text:   func(s string) string { return s },
+		code:   func(s string) string { return "`" + s + "`" },
+		bold:   func(inner string) string { return dialect.Bold + inner + dialect.Bold },
+		italic: func(inner string) string { return dialect.Italic + inner + dialect.Italic },
+		link:   func(label, url string) string { return "[" + label + "](" + url + ")" },
+		escape: func(c string) string {
+			if dialect.Escapes {
+				return `\` + c
+			}
+			return c
+		},
+	})
+}
+
+func renderLines(body string, format inlineFormat) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = renderInline(line, format)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderInline converts the inline formatting of one line. Unclosed markers are kept as text.
+func renderInline(line string, format inlineFormat) string {
+	var out strings.Builder
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			out.WriteString(format.escape(line[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(line[i+1:], '`'); end >= 0 {
+				out.WriteString(format.code(line[i+1 : i+1+end]))
+				i += end + 2
+				continue
+			}
+		case c == '*' || c == '_':
+			marker := line[i : i+1]
+			if strings.HasPrefix(line[i:], "**") {
+				marker = "**"
+			}
+			rest := line[i+len(marker):]
+			if end := strings.Index(rest, marker); end > 0 {
+				inner := renderInline(rest[:end], format)
+				if c == '*' {
+					out.WriteString(format.bold(inner))
+				} else {
+					out.WriteString(format.italic(inner))
+				}
+				i += len(marker) + end + len(marker)
+				continue
+			}
+		case c == '[':
+			if label, url, n, ok := cutLink(line[i:]); ok {
+				out.WriteString(format.link(renderInline(label, format), url))
+				i += n
+				continue
+			}
+		}
+		out.WriteString(format.text(line[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// cutLink parses '[label](url)' at the start of s and returns its length
+func cutLink(s string) (label, url string, n int, ok bool) {
+	closing := strings.Index(s, "](")
+	if closing < 0 {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	return s[1:closing], s[closing+2 : closing+2+end], closing + 2 + end + 1, true
+}
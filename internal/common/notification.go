@@ -0,0 +1,22 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// PlainTitle returns the rendered title of a notification, or the first line of its body as
+// plain text for notifications without one, e.g. from custom webhooks
+func PlainTitle(notification domain.Notification) string {
+	title := notification.Title
+	if title == "" {
+		title, _, _ = strings.Cut(strings.TrimSpace(MarkdownToText(notification.Body)), "\n")
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// Truncate shortens s to at most limit characters, marking the cut with an ellipsis
+func Truncate(s string, limit int) string {
+	return truncate(limit, s)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package common
+
+import "os/exec"
+
+// KillProcessGroup is a no-op, cancelling kills the command only
+func KillProcessGroup(cmd *exec.Cmd) {}
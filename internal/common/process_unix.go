@@ -0,0 +1,17 @@
+//go:build unix
+
+package common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// KillProcessGroup makes cancelling the command kill the processes it started as well,
+// e.g. the children of a shell script
+func KillProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+const defaultTemplateName = "default"
+
+// TemplateRenderer looks up and executes event templates of an inbound adapter
+type TemplateRenderer struct {
+	templates               map[string]*template.Template // templates bound to the functions of each supported locale
+	subjects                map[string]*template.Template // one line titles, e.g. email subjects; nil if the adapter has none
+	disableUnknownTemplates bool
+}
+
+// NewTemplateRenderer prepares the body templates of an adapter and its optional subject templates,
+// which are looked up by the same names and render the notification title.
+func NewTemplateRenderer(templates, subjects *template.Template, disableUnknownTemplates bool) (*TemplateRenderer, error) {
+	localized, err := localizeTemplates(templates)
+	if err != nil {
+		return nil, err
+	}
+
+	var localizedSubjects map[string]*template.Template
+	if subjects != nil {
+		if localizedSubjects, err = localizeTemplates(subjects); err != nil {
+			return nil, err
+		}
+	}
+
+	return &TemplateRenderer{
+		templates:               localized,
+		subjects:                localizedSubjects,
+		disableUnknownTemplates: disableUnknownTemplates,
+	}, nil
+}
+
+func localizeTemplates(templates *template.Template) (map[string]*template.Template, error) {
+	localized := make(map[string]*template.Template)
+	for _, locale := range SupportedLocales() {
+		clone, err := templates.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone templates for locale '%s': %w", locale, err)
+		}
+		localized[locale] = clone.Funcs(LocaleFuncs(locale))
+	}
+	return localized, nil
+}
+
+// Render executes the most specific template for the event.
+// It returns nil if the event has no template and unknown templates are disabled.
+func (r *TemplateRenderer) Render(eventName string, data interface{}, opts domain.RenderOptions) (*domain.Notification, error) {
+	templates := r.templates[ResolveLocale(opts.Locale)]
+
+	templateName := lookupTemplate(templates, eventName, opts)
+	if templateName == "" {
+		if r.disableUnknownTemplates {
+			return nil, nil
+		}
+		templateName = lookupTemplate(templates, defaultTemplateName, domain.RenderOptions{Locale: opts.Locale})
+	}
+
+	var message bytes.Buffer
+	if err := templates.ExecuteTemplate(&message, templateName, data); err != nil {
+		return nil, fmt.Errorf("error executing template '%s': %w", templateName, err)
+	}
+
+	title, err := r.renderSubject(eventName, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, buttons := extractButtons(message.String())
+	return &domain.Notification{Title: title, Body: body, Buttons: buttons}, nil
+}
+
+// renderSubject executes the subject template of the event, or the default one, as a single line
+func (r *TemplateRenderer) renderSubject(eventName string, data interface{}, opts domain.RenderOptions) (string, error) {
+	subjects := r.subjects[ResolveLocale(opts.Locale)]
+	if subjects == nil {
+		return "", nil
+	}
+
+	templateName := lookupTemplate(subjects, eventName, opts)
+	if templateName == "" {
+		templateName = lookupTemplate(subjects, defaultTemplateName, domain.RenderOptions{Locale: opts.Locale})
+	}
+	if templateName == "" {
+		return "", nil
+	}
+
+	var subject bytes.Buffer
+	if err := subjects.ExecuteTemplate(&subject, templateName, data); err != nil {
+		return "", fmt.Errorf("error executing subject template '%s': %w", templateName, err)
+	}
+	return strings.Join(strings.Fields(subject.String()), " "), nil
+}
+
+func lookupTemplate(templates *template.Template, eventName string, opts domain.RenderOptions) string {
+	for _, candidate := range GetTemplateCandidates(eventName, opts.Variant, opts.Locale) {
+		if templates.Lookup(candidate) != nil {
+			return candidate
+		}
+	}
+	return ""
+}
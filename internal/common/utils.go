@@ -20,3 +20,26 @@ func GetUniqueValues[S ~[]E, E any, T comparable](sourceSlice S, getValue func(E
 func GetTemplatePath(name string) string {
 	return fmt.Sprintf("%s.tmpl", name)
 }
+
+// GetTemplateCandidates returns template paths for an event from the most to the least specific.
+// A matching locale is preferred over a matching variant: push.compact.ru, push.ru, push.compact, push.
+func GetTemplateCandidates(name, variant, locale string) []string {
+	variants := []string{""}
+	if variant != "" {
+		variants = []string{"." + variant, ""}
+	}
+
+	locales := []string{}
+	for _, l := range GetLocaleChain(locale) {
+		locales = append(locales, "."+l)
+	}
+	locales = append(locales, "")
+
+	candidates := make([]string, 0, len(variants)*len(locales))
+	for _, l := range locales {
+		for _, v := range variants {
+			candidates = append(candidates, GetTemplatePath(name+v+l))
+		}
+	}
+	return candidates
+}
@@ -1,24 +1,20 @@
 package config
 
-import "github.com/mitchellh/mapstructure"
+import (
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
+)
+
+// The types are registered by the adapter packages, see internal/adapters/registry
 type NotifierType string
 type WebhookType string
 
 type NotifierName string
 type WebhookName string
 
-const (
-	NotifierTypeTelegram NotifierType = "telegram"
-	NotifierTypeEmail    NotifierType = "email"
-)
-
-const (
-	WebhookTypeGitHub   WebhookType = "github"
-	WebhookTypeKanboard WebhookType = "kanboard"
-	WebhookTypeCustom   WebhookType = "custom"
-)
-
 type Config struct {
 	Env                     string           `mapstructure:"env"`
 	Addr                    string           `mapstructure:"addr"`
@@ -26,6 +22,8 @@ type Config struct {
 	Notifiers               []NotifierConfig `mapstructure:"notifiers"`
 	Recipients              []Recipient      `mapstructure:"recipients"`
 	Logger                  Logger           `mapstructure:"logger"`
+	Storage                 Storage          `mapstructure:"storage"`
+	Reload                  Reload           `mapstructure:"reload"`
 	DisableUnknownTemplates bool             `mapstructure:"disable_unknown_templates"` // TODO: moved to webhookConfig
 }
 
@@ -36,6 +34,17 @@ type Logger struct {
 	UDPAddress string `mapstructure:"udp_address"`
 }
 
+type Storage struct {
+	Path      string        `mapstructure:"path"`       // JSON file for adapter state such as message correlations; in memory only if empty
+	EntityTTL time.Duration `mapstructure:"entity_ttl"` // how long the state of a PR or task is kept after its last event, 30 days by default
+}
+
+// Reload configures reloading the config file while running, it is always reloaded on SIGHUP
+type Reload struct {
+	Watch    bool          `mapstructure:"watch"`    // reload when the content of the file changes
+	Interval time.Duration `mapstructure:"interval"` // how often the file is checked for changes, '2s' by default
+}
+
 type NotifierConfig struct {
 	Name     NotifierName           `mapstructure:"name"`
 	Type     NotifierType           `mapstructure:"type"`
@@ -43,32 +52,105 @@ type NotifierConfig struct {
 }
 
 func (sc *NotifierConfig) DecodeSettings(v interface{}) error {
-	return mapstructure.Decode(sc.Settings, v)
+	return decode(sc.Settings, v, false)
+}
+
+// DecodeSettingsStrict is DecodeSettings failing on keys the settings struct does not have, such as typos
+func (sc *NotifierConfig) DecodeSettingsStrict(v interface{}) error {
+	return decode(sc.Settings, v, true)
+}
+
+// decode fills a settings struct from a YAML map, accepting durations such as '1s'
+func decode(input, output interface{}, strict bool) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:  mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused: strict,
+		Result:      output,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(input); err != nil {
+		var decodeErr *mapstructure.Error
+		if errors.As(err, &decodeErr) {
+			// one line per config problem instead of the multi-line summary of mapstructure
+			for i, msg := range decodeErr.Errors {
+				decodeErr.Errors[i] = strings.Replace(strings.TrimPrefix(msg, "'' "), "has invalid keys", "unknown keys", 1)
+			}
+			return errors.New(strings.Join(decodeErr.Errors, "; "))
+		}
+		return err
+	}
+	return nil
 }
 
 type WebhookConfig struct {
-	Name       WebhookName `mapstructure:"name"`
-	Path       string      `mapstructure:"path"`
-	Type       WebhookType `mapstructure:"type"`
-	Secret     string      `mapstructure:"secret"`
-	BaseURL    string      `mapstructure:"base_url"`
-	Recipients []string    `mapstructure:"recipients"`
+	Name            WebhookName            `mapstructure:"name"`
+	Path            string                 `mapstructure:"path"`
+	Type            WebhookType            `mapstructure:"type"`
+	Secret          string                 `mapstructure:"secret"`
+	BaseURL         string                 `mapstructure:"base_url"`
+	TemplateVariant string                 `mapstructure:"template_variant"` // default variant for all recipients of the webhook
+	Digest          DigestConfig           `mapstructure:"digest"`
+	Settings        map[string]interface{} `mapstructure:"settings"` // of the webhook type, e.g. the command of a plugin
+	Recipients      []string               `mapstructure:"recipients"`
+}
+
+func (wc *WebhookConfig) DecodeSettings(v interface{}) error {
+	return decode(wc.Settings, v, false)
+}
+
+// DecodeSettingsStrict is DecodeSettings failing on keys the settings struct does not have, such as typos
+func (wc *WebhookConfig) DecodeSettingsStrict(v interface{}) error {
+	return decode(wc.Settings, v, true)
+}
+
+// DigestConfig batches the notifications of a webhook into one message per recipient
+type DigestConfig struct {
+	Window   time.Duration `mapstructure:"window"`    // e.g. '60s', flushes the window opened by the first buffered event
+	MaxItems int           `mapstructure:"max_items"` // flushes early when this many events are buffered
+	Schedule string        `mapstructure:"schedule"`  // daily 'HH:MM' delivery, used instead of the window
+	Timezone string        `mapstructure:"timezone"`  // IANA timezone of the schedule, UTC by default
+}
+
+func (d DigestConfig) Enabled() bool {
+	return d.Window > 0 || d.Schedule != ""
 }
 
 type Recipient struct {
-	Name     string       `mapstructure:"name"`
-	Target   string       `mapstructure:"target"`
-	Notifier NotifierName `mapstructure:"notifier"`
+	Name            string                 `mapstructure:"name"`
+	Target          string                 `mapstructure:"target"`
+	Notifier        NotifierName           `mapstructure:"notifier"`
+	TemplateVariant string                 `mapstructure:"template_variant"` // overrides the webhook template variant
+	Locale          string                 `mapstructure:"locale"`           // e.g. 'en' or 'ru', selects '<event>.<locale>.tmpl' templates
+	Schedule        ScheduleConfig         `mapstructure:"schedule"`
+	Options         map[string]interface{} `mapstructure:"options"` // notifier specific target options, e.g. telegram.Options
 }
 
-type TelegramSettings struct {
-	Token string `mapstructure:"token"`
+func (r Recipient) DecodeOptions(v interface{}) error {
+	return decode(r.Options, v, false)
+}
+
+// DecodeOptionsStrict is DecodeOptions failing on keys the options struct does not have, such as typos
+func (r Recipient) DecodeOptionsStrict(v interface{}) error {
+	return decode(r.Options, v, true)
+}
+
+const (
+	ScheduleOutsideDefer  = "defer"
+	ScheduleOutsideDrop   = "drop"
+	ScheduleOutsideSilent = "silent"
+)
+
+// ScheduleConfig limits the delivery of notifications to the active hours of a recipient
+type ScheduleConfig struct {
+	Timezone       string   `mapstructure:"timezone"`        // IANA timezone, UTC by default
+	ActiveHours    string   `mapstructure:"active_hours"`    // 'HH:MM-HH:MM', may wrap midnight; all day by default
+	Weekdays       []string `mapstructure:"weekdays"`        // 'mon' ... 'sun'; every day by default
+	Outside        string   `mapstructure:"outside"`         // 'defer' (default), 'drop' or 'silent'
+	BypassSeverity string   `mapstructure:"bypass_severity"` // notifications at least this severe ignore the schedule, 'critical' by default
 }
 
-type EmailSettings struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	From     string `mapstructure:"from"`
+func (s ScheduleConfig) Enabled() bool {
+	return s.ActiveHours != "" || len(s.Weekdays) > 0
 }
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate checks the names and paths of the config and the references between its sections, returning
+// every problem found. The settings of webhooks and notifiers are checked by their adapters.
+func (c *Config) Validate() []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	notifiers := make(map[NotifierName]bool)
+	for i, notifier := range c.Notifiers {
+		switch {
+		case notifier.Name == "":
+			fail("notifiers[%d]: empty 'name' value", i)
+		case notifiers[notifier.Name]:
+			fail("duplicate notifier name '%s'", notifier.Name)
+		}
+		notifiers[notifier.Name] = true
+	}
+
+	recipients := make(map[string]bool)
+	for i, recipient := range c.Recipients {
+		switch {
+		case recipient.Name == "":
+			fail("recipients[%d]: empty 'name' value", i)
+		case recipients[recipient.Name]:
+			fail("duplicate recipient name '%s'", recipient.Name)
+		}
+		recipients[recipient.Name] = true
+
+		if recipient.Notifier == "" {
+			fail("recipient '%s': empty 'notifier' value", recipient.Name)
+		} else if !notifiers[recipient.Notifier] {
+			fail("recipient '%s': unknown notifier '%s'", recipient.Name, recipient.Notifier)
+		}
+	}
+
+	webhooks := make(map[WebhookName]bool)
+	paths := make(map[string]WebhookName)
+	for i, webhook := range c.Webhooks {
+		switch {
+		case webhook.Name == "":
+			fail("webhooks[%d]: empty 'name' value", i)
+		case webhooks[webhook.Name]:
+			fail("duplicate webhook name '%s'", webhook.Name)
+		}
+		webhooks[webhook.Name] = true
+
+		switch other, taken := paths[webhook.Path]; {
+		case webhook.Path == "":
+			fail("webhook '%s': empty 'path' value", webhook.Name)
+		case !strings.HasPrefix(webhook.Path, "/"):
+			fail("webhook '%s': path '%s' must start with '/'", webhook.Name, webhook.Path)
+		case taken:
+			fail("webhook '%s': path '%s' is used by '%s' already", webhook.Name, webhook.Path, other)
+		default:
+			paths[webhook.Path] = webhook.Name
+		}
+
+		for _, name := range webhook.Recipients {
+			if !recipients[name] {
+				fail("webhook '%s': unknown recipient '%s'", webhook.Name, name)
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,15 @@
+package domain
+
+// Event is a verified inbound webhook that is ready to be rendered
+type Event struct {
+	Name      string
+	Payload   interface{}
+	Severity  Severity
+	EntityKey string // stable key of the entity the event is about, e.g. 'owner/repo#12'
+}
+
+// RenderOptions selects how an event is rendered for a group of recipients
+type RenderOptions struct {
+	Variant string
+	Locale  string
+}
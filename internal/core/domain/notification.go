@@ -5,4 +5,17 @@ type Notification struct {
 	Title     string
 	Body      string
 	ParseMode string
+	Severity  Severity
+	Silent    bool // deliver without a sound or push alert where the channel supports it
+	Buttons   []Button
+	Webhook   string      // name of the webhook the notification comes from
+	Event     string      // name of the event the notification was rendered for
+	EntityKey string      // identifies the PR, issue or task the notification is about, empty if none
+	Payload   interface{} // parsed payload of the event, a list of payloads for digests
+}
+
+// Button is a link the channel may show as an action next to the message
+type Button struct {
+	Text string
+	URL  string
 }
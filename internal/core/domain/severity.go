@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity describes how urgent a notification is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// ParseSeverity accepts a severity name case-insensitively. An empty value means info.
+func ParseSeverity(value string) (Severity, error) {
+	if value == "" {
+		return SeverityInfo, nil
+	}
+	severity := Severity(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("unknown severity '%s'", value)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether s is as urgent as other. Unknown severities rank as info.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
@@ -19,7 +19,10 @@ func (r *WebhookRequest) GetHeader(key string) string {
 }
 
 type WebhookHandler interface {
-	Handle(ctx context.Context, req WebhookRequest) (*domain.Notification, error)
+	// Handle verifies and parses the request. A nil event means there is nothing to deliver.
+	Handle(ctx context.Context, req WebhookRequest) (*domain.Event, error)
+	// Render formats the event for a group of recipients. A nil notification means the event should be skipped.
+	Render(ctx context.Context, event domain.Event, opts domain.RenderOptions) (*domain.Notification, error)
 }
 
 type Service interface {
@@ -3,9 +3,34 @@ package ports
 import (
 	"context"
 
+	"github.com/shanth1/hookrelay/internal/config"
 	"github.com/shanth1/hookrelay/internal/core/domain"
 )
 
 type Notifier interface {
-	Send(ctx context.Context, target string, notification domain.Notification) error
+	Send(ctx context.Context, recipient config.Recipient, notification domain.Notification) error
+}
+
+// BatchNotifier is implemented by notifiers that deliver one notification to several recipients at once.
+// SendBatch returns the errors of the failed recipients by recipient name, nil if all succeeded.
+type BatchNotifier interface {
+	Notifier
+	SendBatch(ctx context.Context, recipients []config.Recipient, notification domain.Notification) map[string]error
+}
+
+// RecipientFilter is implemented by notifiers whose recipients can opt out of notifications at runtime
+type RecipientFilter interface {
+	Accepts(ctx context.Context, recipient config.Recipient, notification domain.Notification) bool
+}
+
+// Drainer is implemented by notifiers that deliver in the background after Send returned.
+// Drain waits for the deliveries queued so far until ctx is done.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// Runner is implemented by adapters with background work, such as polling for bot commands.
+// Run blocks until ctx is cancelled.
+type Runner interface {
+	Run(ctx context.Context) error
 }
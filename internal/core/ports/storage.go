@@ -0,0 +1,26 @@
+package ports
+
+import "context"
+
+// Store persists small pieces of adapter state, such as the messages sent for an entity
+type Store interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// ExpiringStore is a Store that can forget values nobody needs any more, such as the message of a PR
+// closed long ago. Stores without it keep such values like any other.
+type ExpiringStore interface {
+	Store
+	// SetExpiring stores a value that is removed once it has not been set or read for the retention period of the store
+	SetExpiring(ctx context.Context, key, value string) error
+}
+
+// SetExpiring stores a value that may expire if the store supports it, see ExpiringStore
+func SetExpiring(ctx context.Context, store Store, key, value string) error {
+	if expiring, ok := store.(ExpiringStore); ok {
+		return expiring.SetExpiring(ctx, key, value)
+	}
+	return store.Set(ctx, key, value)
+}
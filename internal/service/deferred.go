@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// deferredKey is the store key of the deliveries deferred by recipient schedules when the service stopped
+const deferredKey = "service:deferred"
+
+type storedDelivery struct {
+	Recipient       string              `json:"recipient"`
+	TemplateVariant string              `json:"template_variant,omitempty"`
+	Notification    domain.Notification `json:"notification"`
+}
+
+// saveDeferred adds deliveries to the ones kept in the store, in case the previous ones were never restored
+func (s *Service) saveDeferred(ctx context.Context, deliveries []delivery) error {
+	stored, err := s.loadDeferred(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		stored = append(stored, storedDelivery{
+			Recipient:       d.recipient.Name,
+			TemplateVariant: d.recipient.TemplateVariant,
+			Notification:    d.notification,
+		})
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode deferred notifications: %w", err)
+	}
+	return s.store.Set(ctx, deferredKey, string(data))
+}
+
+func (s *Service) loadDeferred(ctx context.Context) ([]storedDelivery, error) {
+	value, ok, err := s.store.Get(ctx, deferredKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var stored []storedDelivery
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode deferred notifications: %w", err)
+	}
+	return stored, nil
+}
+
+// RestoreDeferred schedules the notifications deferred when the previous run shut down again,
+// for the recipients of the same name. Those due already are sent at once.
+func (s *Service) RestoreDeferred(ctx context.Context) error {
+	stored, err := s.loadDeferred(ctx)
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+	if err := s.store.Delete(ctx, deferredKey); err != nil {
+		return err
+	}
+
+	deliveries := make([]delivery, 0, len(stored))
+	for _, sd := range stored {
+		deliveries = append(deliveries, delivery{
+			recipient:    config.Recipient{Name: sd.Recipient, TemplateVariant: sd.TemplateVariant},
+			notification: sd.Notification,
+		})
+	}
+	s.logger.Info().Int("count", len(deliveries)).Msg("restored notifications deferred by recipient schedules")
+	s.adopt(deliveries)
+	return nil
+}
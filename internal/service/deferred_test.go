@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/adapters/storage"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+)
+
+// recorder is a notifier keeping what it was asked to send
+type recorder struct {
+	mu   sync.Mutex
+	sent []delivery
+}
+
+func (r *recorder) Send(_ context.Context, recipient config.Recipient, notification domain.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, delivery{recipient: recipient, notification: notification})
+	return nil
+}
+
+func (r *recorder) deliveries() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.sent...)
+}
+
+func newTestService(t *testing.T, cfg *config.Config, notifier ports.Notifier, store ports.Store) *Service {
+	t.Helper()
+	s, err := New(cfg, nil, map[config.NotifierName]ports.Notifier{"recorder": notifier}, store, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// closedToday is a schedule whose window is closed all of today, in UTC
+func closedToday() config.ScheduleConfig {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Weekday()
+	return config.ScheduleConfig{Weekdays: []string{tomorrow.String()}}
+}
+
+func TestDeferredSurviveRestart(t *testing.T) {
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	scheduled := config.Recipient{Name: "ops", Notifier: "recorder", Target: "old", Schedule: closedToday()}
+	first := newTestService(t, &config.Config{Recipients: []config.Recipient{scheduled}}, &recorder{}, store)
+	d := delivery{
+		recipient:    scheduled,
+		notification: domain.Notification{Title: "Push", Body: "main updated", Severity: domain.SeverityInfo, Webhook: "ci", Event: "push"},
+	}
+	d.recipient.TemplateVariant = "compact"
+	first.broadcast(ctx, []delivery{d})
+	if err := first.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next run has changed the target and dropped the schedule
+	notifier := &recorder{}
+	moved := config.Recipient{Name: "ops", Notifier: "recorder", Target: "new"}
+	second := newTestService(t, &config.Config{Recipients: []config.Recipient{moved}}, notifier, store)
+	if err := second.RestoreDeferred(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := notifier.deliveries()
+	if len(sent) != 1 {
+		t.Fatalf("%d deliveries after the restart, want 1", len(sent))
+	}
+	got := sent[0]
+	if got.recipient.Target != "new" || got.recipient.TemplateVariant != "compact" {
+		t.Errorf("recipient = %+v, want the new target with the variant of the deferred notification", got.recipient)
+	}
+	if got.notification.Body != "main updated" || got.notification.Event != "push" {
+		t.Errorf("notification = %+v", got.notification)
+	}
+	if _, ok, _ := store.Get(ctx, deferredKey); ok {
+		t.Error("deferred notifications kept in the store after they were restored")
+	}
+}
+
+func TestSaveDeferredKeepsUnrestored(t *testing.T) {
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s := newTestService(t, &config.Config{}, &recorder{}, store)
+
+	for _, body := range []string{"first", "second"} {
+		d := delivery{recipient: config.Recipient{Name: "ops"}, notification: domain.Notification{Body: body}}
+		if err := s.saveDeferred(ctx, []delivery{d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, err := s.loadDeferred(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || stored[0].Notification.Body != "first" || stored[1].Notification.Body != "second" {
+		t.Errorf("stored = %+v, want both runs", stored)
+	}
+}
+
+func TestRestoreDeferredDiscardsRemovedRecipients(t *testing.T) {
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	notifier := &recorder{}
+	s := newTestService(t, &config.Config{Recipients: []config.Recipient{{Name: "ops", Notifier: "recorder"}}}, notifier, store)
+
+	if err := s.saveDeferred(ctx, []delivery{
+		{recipient: config.Recipient{Name: "gone"}, notification: domain.Notification{Body: "lost"}},
+		{recipient: config.Recipient{Name: "ops"}, notification: domain.Notification{Body: "kept"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreDeferred(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := notifier.deliveries(); len(sent) != 1 || sent[0].notification.Body != "kept" {
+		t.Errorf("sent %+v, want only the notification of the remaining recipient", sent)
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+const digestSeparator = "\n\n———\n\n"
+
+// digestPolicy decides when the buffered notifications of a webhook are flushed
+type digestPolicy struct {
+	window    time.Duration
+	maxItems  int
+	scheduled bool
+	hour      int
+	minute    int
+	location  *time.Location
+}
+
+func newDigestPolicy(cfg config.DigestConfig) (*digestPolicy, error) {
+	policy := &digestPolicy{
+		window:   cfg.Window,
+		maxItems: cfg.MaxItems,
+		location: time.UTC,
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown digest timezone '%s': %w", cfg.Timezone, err)
+		}
+		policy.location = loc
+	}
+	if cfg.Schedule != "" {
+		at, err := time.Parse("15:04", cfg.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest schedule '%s', expected HH:MM: %w", cfg.Schedule, err)
+		}
+		policy.scheduled = true
+		policy.hour, policy.minute = at.Hour(), at.Minute()
+	}
+	return policy, nil
+}
+
+// delay returns how long a buffer opened at now waits before it is flushed
+func (p *digestPolicy) delay(now time.Time) time.Duration {
+	if !p.scheduled {
+		return p.window
+	}
+	local := now.In(p.location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), p.hour, p.minute, 0, 0, p.location)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+type digestKey struct {
+	webhook   config.WebhookName
+	recipient string
+}
+
+type digestBuffer struct {
+	webhook       config.WebhookName
+	recipient     config.Recipient
+	notifications []domain.Notification
+	timer         *time.Timer
+	deadline      time.Time // when the timer fires
+}
+
+// digester buffers notifications per webhook and recipient until their policy flushes them
+type digester struct {
+	mu       sync.Mutex
+	configs  map[config.WebhookName]config.DigestConfig // of the webhooks with a policy
+	policies map[config.WebhookName]*digestPolicy
+	buffers  map[digestKey]*digestBuffer
+	flush    func(buf *digestBuffer)
+	// counts the open buffers, so a buffer whose timer fires during shutdown is waited for as well
+	pending *sync.WaitGroup
+}
+
+func newDigester(cfgs map[config.WebhookName]config.DigestConfig, pending *sync.WaitGroup, flush func(buf *digestBuffer)) (*digester, error) {
+	configs := make(map[config.WebhookName]config.DigestConfig)
+	policies := make(map[config.WebhookName]*digestPolicy)
+	for webhook, cfg := range cfgs {
+		if !cfg.Enabled() {
+			continue
+		}
+		policy, err := newDigestPolicy(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("webhook '%s': %w", webhook, err)
+		}
+		configs[webhook] = cfg
+		policies[webhook] = policy
+	}
+	return &digester{
+		configs:  configs,
+		policies: policies,
+		buffers:  make(map[digestKey]*digestBuffer),
+		flush:    flush,
+		pending:  pending,
+	}, nil
+}
+
+func (d *digester) enabled(webhook config.WebhookName) bool {
+	_, ok := d.policies[webhook]
+	return ok
+}
+
+// add buffers a delivery. The buffer is flushed right away once it reaches the max item count.
+func (d *digester) add(webhook config.WebhookName, dlv delivery) {
+	policy := d.policies[webhook]
+	key := digestKey{webhook: webhook, recipient: dlv.recipient.Name}
+
+	d.mu.Lock()
+	buf, ok := d.buffers[key]
+	if !ok {
+		now := time.Now()
+		buf = &digestBuffer{webhook: webhook, recipient: dlv.recipient, deadline: now.Add(policy.delay(now))}
+		d.pending.Add(1)
+		buf.timer = time.AfterFunc(buf.deadline.Sub(now), func() { d.flushKey(key, buf) })
+		d.buffers[key] = buf
+	}
+	buf.notifications = append(buf.notifications, dlv.notification)
+	full := policy.maxItems > 0 && len(buf.notifications) >= policy.maxItems
+	d.mu.Unlock()
+
+	if full {
+		d.flushKey(key, buf)
+	}
+}
+
+// flushKey sends the buffer unless it was already flushed by its timer or the max item count
+func (d *digester) flushKey(key digestKey, buf *digestBuffer) {
+	d.mu.Lock()
+	if d.buffers[key] != buf {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.buffers, key)
+	buf.timer.Stop()
+	d.mu.Unlock()
+
+	defer d.pending.Done()
+	d.flush(buf)
+}
+
+// flushAll sends every pending buffer, e.g. on shutdown
+func (d *digester) flushAll() {
+	d.mu.Lock()
+	buffers := d.buffers
+	d.buffers = make(map[digestKey]*digestBuffer)
+	d.mu.Unlock()
+
+	for _, buf := range buffers {
+		buf.timer.Stop()
+		d.flush(buf)
+		d.pending.Done()
+	}
+}
+
+// handover passes the pending buffers to the digester of a reloaded config, which flushes them when
+// they were due. Buffers of removed recipients, of webhooks whose digest config changed and buffers
+// already due are flushed right away instead.
+func (d *digester) handover(next *digester, recipients map[string]config.Recipient) {
+	d.mu.Lock()
+	buffers := d.buffers
+	d.buffers = make(map[digestKey]*digestBuffer)
+	d.mu.Unlock()
+
+	for key, buf := range buffers {
+		recipient, ok := recipients[key.recipient]
+		cfg, enabled := next.configs[key.webhook]
+		// a timer that already fired waits for the lock in flushKey, which skips the buffer taken from it
+		if !buf.timer.Stop() || !ok || !enabled || cfg != d.configs[key.webhook] {
+			d.flush(buf)
+			d.pending.Done()
+			continue
+		}
+		recipient.TemplateVariant = buf.recipient.TemplateVariant
+		buf.recipient = recipient
+		next.adopt(key, buf)
+		d.pending.Done()
+	}
+}
+
+// adopt takes over a buffer of the previous digester, merging it with the one opened since the reload
+func (d *digester) adopt(key digestKey, prev *digestBuffer) {
+	policy := d.policies[key.webhook]
+
+	d.mu.Lock()
+	buf, ok := d.buffers[key]
+	if ok {
+		buf.notifications = append(prev.notifications, buf.notifications...)
+	} else {
+		buf = &digestBuffer{webhook: prev.webhook, recipient: prev.recipient, notifications: prev.notifications, deadline: prev.deadline}
+		d.pending.Add(1)
+		buf.timer = time.AfterFunc(time.Until(buf.deadline), func() { d.flushKey(key, buf) })
+		d.buffers[key] = buf
+	}
+	full := policy.maxItems > 0 && len(buf.notifications) >= policy.maxItems
+	d.mu.Unlock()
+
+	if full {
+		d.flushKey(key, buf)
+	}
+}
+
+// combineNotifications lists the buffered notifications of a recipient in a single message under a header
+// in its locale. The digest is as severe as its most severe item, and keeps the event of that item and
+// the entity the items share, if any.
+func combineNotifications(templates *common.TemplateRenderer, buf *digestBuffer) (domain.Notification, error) {
+	notifications := buf.notifications
+	if len(notifications) == 1 {
+		return notifications[0], nil
+	}
+
+	header, err := templates.Render("digest", map[string]interface{}{
+		"count":   len(notifications),
+		"webhook": string(buf.webhook),
+	}, domain.RenderOptions{Variant: buf.recipient.TemplateVariant, Locale: buf.recipient.Locale})
+	if err != nil {
+		return domain.Notification{}, err
+	}
+
+	bodies := make([]string, 0, len(notifications))
+	payloads := make([]interface{}, 0, len(notifications))
+	var buttons []domain.Button
+	main := notifications[0]
+	entityKey, silent := main.EntityKey, true
+	for _, n := range notifications {
+		bodies = append(bodies, strings.TrimSpace(n.Body))
+		payloads = append(payloads, n.Payload)
+		for _, b := range n.Buttons {
+			if !slices.Contains(buttons, b) {
+				buttons = append(buttons, b)
+			}
+		}
+		if !main.Severity.AtLeast(n.Severity) {
+			main = n
+		}
+		if n.EntityKey != entityKey {
+			entityKey = ""
+		}
+		silent = silent && n.Silent
+	}
+
+	return domain.Notification{
+		Title:     header.Title,
+		Body:      strings.TrimSpace(header.Body) + digestSeparator + strings.Join(bodies, digestSeparator),
+		ParseMode: notifications[0].ParseMode,
+		Severity:  main.Severity,
+		Silent:    silent,
+		Buttons:   buttons,
+		Webhook:   string(buf.webhook),
+		Event:     main.Event,
+		EntityKey: entityKey,
+		Payload:   payloads,
+	}, nil
+}
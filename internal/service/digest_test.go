@@ -0,0 +1,309 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+func TestDigestPolicyDelay(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  config.DigestConfig
+		now  time.Time
+		want time.Duration
+	}{
+		{
+			name: "window",
+			cfg:  config.DigestConfig{Window: time.Minute},
+			now:  time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC),
+			want: time.Minute,
+		},
+		{
+			name: "schedule later today",
+			cfg:  config.DigestConfig{Schedule: "09:00", Timezone: "Europe/Berlin"},
+			now:  time.Date(2024, 3, 30, 8, 30, 0, 0, berlin),
+			want: 30 * time.Minute,
+		},
+		{
+			name: "schedule passed today",
+			cfg:  config.DigestConfig{Schedule: "09:00", Timezone: "Europe/Berlin"},
+			now:  time.Date(2024, 3, 29, 9, 0, 0, 0, berlin),
+			want: 24 * time.Hour,
+		},
+		{
+			name: "schedule across the switch to summer time",
+			cfg:  config.DigestConfig{Schedule: "09:00", Timezone: "Europe/Berlin"},
+			now:  time.Date(2024, 3, 30, 10, 0, 0, 0, berlin),
+			want: 22 * time.Hour,
+		},
+		{
+			name: "schedule in UTC by default",
+			cfg:  config.DigestConfig{Schedule: "09:00"},
+			now:  time.Date(2024, 3, 30, 8, 0, 0, 0, berlin), // 07:00 UTC
+			want: 2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newDigestPolicy(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.delay(tt.now); got != tt.want {
+				t.Errorf("delay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDigestPolicyErrors(t *testing.T) {
+	for _, cfg := range []config.DigestConfig{
+		{Schedule: "9am"},
+		{Schedule: "25:00"},
+		{Schedule: "09:00", Timezone: "Mars/Olympus"},
+	} {
+		if _, err := newDigestPolicy(cfg); err == nil {
+			t.Errorf("newDigestPolicy(%+v) succeeded", cfg)
+		}
+	}
+}
+
+// testDigester collects the flushed buffers
+type testDigester struct {
+	*digester
+	pending sync.WaitGroup
+	flushed chan *digestBuffer
+}
+
+func newTestDigester(t *testing.T, cfg config.DigestConfig) *testDigester {
+	t.Helper()
+	return newTestDigesterOf(t, map[config.WebhookName]config.DigestConfig{"ci": cfg})
+}
+
+func newTestDigesterOf(t *testing.T, cfgs map[config.WebhookName]config.DigestConfig) *testDigester {
+	t.Helper()
+	d := &testDigester{flushed: make(chan *digestBuffer, 10)}
+	digester, err := newDigester(cfgs, &d.pending, func(buf *digestBuffer) {
+		d.flushed <- buf
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.digester = digester
+	return d
+}
+
+func (d *testDigester) addN(n int, recipient string) {
+	for i := 0; i < n; i++ {
+		d.add("ci", delivery{recipient: config.Recipient{Name: recipient}, notification: domain.Notification{Body: "event"}})
+	}
+}
+
+func (d *testDigester) next(t *testing.T, within time.Duration) *digestBuffer {
+	t.Helper()
+	select {
+	case buf := <-d.flushed:
+		return buf
+	case <-time.After(within):
+		t.Fatal("no digest flushed")
+		return nil
+	}
+}
+
+func TestDigesterFlushesWindow(t *testing.T) {
+	d := newTestDigester(t, config.DigestConfig{Window: 50 * time.Millisecond})
+	if d.enabled("other") || !d.enabled("ci") {
+		t.Fatal("digest enabled for the wrong webhooks")
+	}
+
+	d.addN(3, "ops")
+	d.addN(1, "dev")
+	got := map[string]int{}
+	for i := 0; i < 2; i++ {
+		buf := d.next(t, time.Second)
+		got[buf.recipient.Name] = len(buf.notifications)
+	}
+	if got["ops"] != 3 || got["dev"] != 1 {
+		t.Errorf("flushed %v, want a digest per recipient", got)
+	}
+
+	// the next event opens a new window
+	d.addN(1, "ops")
+	if buf := d.next(t, time.Second); len(buf.notifications) != 1 {
+		t.Errorf("second window has %d notifications, want 1", len(buf.notifications))
+	}
+	d.pending.Wait()
+}
+
+func TestDigesterFlushesMaxItems(t *testing.T) {
+	d := newTestDigester(t, config.DigestConfig{Window: time.Hour, MaxItems: 2})
+
+	d.addN(2, "ops")
+	if buf := d.next(t, time.Second); len(buf.notifications) != 2 {
+		t.Errorf("flushed %d notifications, want 2", len(buf.notifications))
+	}
+
+	d.addN(1, "ops")
+	select {
+	case buf := <-d.flushed:
+		t.Fatalf("flushed %d notifications before the window or max items", len(buf.notifications))
+	case <-time.After(50 * time.Millisecond):
+	}
+	d.flushAll()
+	if buf := d.next(t, time.Second); len(buf.notifications) != 1 {
+		t.Errorf("flushAll() sent %d notifications, want 1", len(buf.notifications))
+	}
+	d.pending.Wait()
+}
+
+func TestDigesterWaitsForSchedule(t *testing.T) {
+	d := newTestDigester(t, config.DigestConfig{Schedule: time.Now().UTC().Add(-time.Hour).Format("15:04")})
+
+	d.addN(2, "ops")
+	select {
+	case <-d.flushed:
+		t.Fatal("daily digest flushed before its time")
+	case <-time.After(50 * time.Millisecond):
+	}
+	d.flushAll()
+	if buf := d.next(t, time.Second); len(buf.notifications) != 2 {
+		t.Errorf("flushAll() sent %d notifications, want 2", len(buf.notifications))
+	}
+	d.pending.Wait()
+}
+
+func TestDigesterHandover(t *testing.T) {
+	daily := config.DigestConfig{Schedule: time.Now().UTC().Add(-time.Hour).Format("15:04")}
+	window := config.DigestConfig{Window: 100 * time.Millisecond}
+	old := newTestDigesterOf(t, map[config.WebhookName]config.DigestConfig{"ci": daily, "deploy": {Window: time.Hour}, "alerts": window})
+	for i := 0; i < 2; i++ {
+		old.add("ci", delivery{recipient: config.Recipient{Name: "ops", TemplateVariant: "compact"}, notification: domain.Notification{Body: "event"}})
+	}
+	old.addN(1, "gone")
+	old.add("deploy", delivery{recipient: config.Recipient{Name: "ops"}, notification: domain.Notification{Body: "event"}})
+	old.add("alerts", delivery{recipient: config.Recipient{Name: "ops"}, notification: domain.Notification{Body: "first"}})
+
+	next := newTestDigesterOf(t, map[config.WebhookName]config.DigestConfig{"ci": daily, "deploy": {Window: time.Minute}, "alerts": window})
+	next.add("alerts", delivery{recipient: config.Recipient{Name: "ops"}, notification: domain.Notification{Body: "second"}})
+	old.handover(next.digester, map[string]config.Recipient{"ops": {Name: "ops", Locale: "ru"}})
+
+	// the digest of the removed recipient and the one of the changed config are sent at once
+	got := map[config.WebhookName]string{}
+	for i := 0; i < 2; i++ {
+		buf := old.next(t, time.Second)
+		got[buf.webhook] = buf.recipient.Name
+	}
+	if got["ci"] != "gone" || got["deploy"] != "ops" {
+		t.Errorf("flushed %v on handover, want the removed recipient and the changed config", got)
+	}
+	old.pending.Wait()
+
+	// the window keeps its deadline and merges with the buffer opened since the reload
+	buf := next.next(t, time.Second)
+	if buf.webhook != "alerts" || len(buf.notifications) != 2 || buf.notifications[0].Body != "first" {
+		t.Fatalf("flushed %+v, want the merged window", buf)
+	}
+
+	// the daily digest still waits for its time
+	select {
+	case buf := <-next.flushed:
+		t.Fatalf("flushed %s digest of %d notifications before its time", buf.webhook, len(buf.notifications))
+	case <-time.After(50 * time.Millisecond):
+	}
+	next.flushAll()
+	buf = next.next(t, time.Second)
+	if buf.webhook != "ci" || len(buf.notifications) != 2 {
+		t.Fatalf("flushAll() sent %d notifications of %s, want the daily digest", len(buf.notifications), buf.webhook)
+	}
+	if buf.recipient.Locale != "ru" || buf.recipient.TemplateVariant != "compact" {
+		t.Errorf("recipient = %+v, want the reloaded one with the variant of the buffer", buf.recipient)
+	}
+	next.pending.Wait()
+}
+
+func TestCombineNotifications(t *testing.T) {
+	templates, err := newDigestRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := domain.Button{Text: "Logs", URL: "https://ci.example.com/1"}
+	notifications := []domain.Notification{
+		{Body: "build started", Severity: domain.SeverityInfo, Event: "workflow_job", EntityKey: "run-1", Buttons: []domain.Button{logs}, Silent: true, ParseMode: "Markdown"},
+		{Body: "build failed\n", Severity: domain.SeverityCritical, Event: "workflow_run", EntityKey: "run-1", Buttons: []domain.Button{logs}, Silent: true},
+		{Body: "deploy skipped", Severity: domain.SeverityWarning, Event: "deployment", EntityKey: "run-1"},
+	}
+
+	tests := []struct {
+		name      string
+		recipient config.Recipient
+		items     []domain.Notification
+		title     string
+		entity    string
+		silent    bool
+	}{
+		{
+			name:   "english",
+			items:  notifications,
+			title:  "3 notifications from ci",
+			entity: "run-1",
+		},
+		{
+			name:      "russian",
+			recipient: config.Recipient{Locale: "ru"},
+			items:     notifications[:2],
+			title:     "2 уведомления от ci",
+			entity:    "run-1",
+			silent:    true,
+		},
+		{
+			name:      "regional locale without its own templates",
+			recipient: config.Recipient{Locale: "ru-RU"},
+			items:     append(notifications, domain.Notification{Body: "other", EntityKey: "run-2"}, notifications[0]),
+			title:     "5 уведомлений от ci",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := combineNotifications(templates, &digestBuffer{webhook: "ci", recipient: tt.recipient, notifications: tt.items})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != tt.title {
+				t.Errorf("title = %q, want %q", got.Title, tt.title)
+			}
+			wantBody := "🗂 " + tt.title + digestSeparator + "build started" + digestSeparator + "build failed"
+			if !strings.HasPrefix(got.Body, wantBody) {
+				t.Errorf("body = %q, want it to start with %q", got.Body, wantBody)
+			}
+			if got.Severity != domain.SeverityCritical || got.Event != "workflow_run" {
+				t.Errorf("severity %s of event %s, want the most severe item", got.Severity, got.Event)
+			}
+			if got.EntityKey != tt.entity {
+				t.Errorf("entity = %q, want %q", got.EntityKey, tt.entity)
+			}
+			if got.Silent != tt.silent {
+				t.Errorf("silent = %v, want %v", got.Silent, tt.silent)
+			}
+			if len(got.Buttons) != 1 || got.ParseMode != "Markdown" || got.Webhook != "ci" {
+				t.Errorf("buttons %v, parse mode %q, webhook %q", got.Buttons, got.ParseMode, got.Webhook)
+			}
+			if payloads, ok := got.Payload.([]interface{}); !ok || len(payloads) != len(tt.items) {
+				t.Errorf("payload = %v, want one per item", got.Payload)
+			}
+		})
+	}
+
+	single, err := combineNotifications(templates, &digestBuffer{webhook: "ci", notifications: notifications[:1]})
+	if err != nil || single.Body != "build started" {
+		t.Errorf("combineNotifications() of one = %+v, %v, want it unchanged", single, err)
+	}
+}
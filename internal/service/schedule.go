@@ -0,0 +1,247 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+const minutesPerDay = 24 * 60
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// deliveryWindow is the compiled schedule of a recipient
+type deliveryWindow struct {
+	location *time.Location
+	start    int // minutes since midnight
+	end      int // equal to start for an all-day window
+	weekdays [7]bool
+	outside  string
+	bypass   domain.Severity
+}
+
+func newDeliveryWindow(cfg config.ScheduleConfig) (*deliveryWindow, error) {
+	w := &deliveryWindow{
+		location: time.UTC,
+		outside:  cfg.Outside,
+		bypass:   domain.SeverityCritical,
+	}
+
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone '%s': %w", cfg.Timezone, err)
+		}
+		w.location = loc
+	}
+
+	if cfg.ActiveHours != "" {
+		from, to, found := strings.Cut(cfg.ActiveHours, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid active_hours '%s', expected HH:MM-HH:MM", cfg.ActiveHours)
+		}
+		var err error
+		if w.start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(to); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.Weekdays) == 0 {
+		for i := range w.weekdays {
+			w.weekdays[i] = true
+		}
+	}
+	for _, name := range cfg.Weekdays {
+		short := strings.ToLower(strings.TrimSpace(name))
+		if len(short) > 3 {
+			short = short[:3] // 'monday' is accepted as well as 'mon'
+		}
+		day, ok := weekdayNames[short]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday '%s'", name)
+		}
+		w.weekdays[day] = true
+	}
+
+	switch w.outside {
+	case "":
+		w.outside = config.ScheduleOutsideDefer
+	case config.ScheduleOutsideDefer, config.ScheduleOutsideDrop, config.ScheduleOutsideSilent:
+	default:
+		return nil, fmt.Errorf("unknown outside action '%s'", cfg.Outside)
+	}
+
+	if cfg.BypassSeverity != "" {
+		severity, err := domain.ParseSeverity(cfg.BypassSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bypass_severity: %w", err)
+		}
+		w.bypass = severity
+	}
+
+	return w, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM: %w", value, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// active reports whether t falls into the window. A window that wraps midnight belongs to the day it starts on.
+func (w *deliveryWindow) active(t time.Time) bool {
+	local := t.In(w.location)
+	day := local.Weekday()
+	minute := local.Hour()*60 + local.Minute()
+
+	switch {
+	case w.start == w.end:
+		return w.weekdays[day]
+	case w.start < w.end:
+		return w.weekdays[day] && minute >= w.start && minute < w.end
+	case minute >= w.start:
+		return w.weekdays[day]
+	case minute < w.end:
+		return w.weekdays[(day+6)%7]
+	default:
+		return false
+	}
+}
+
+// next returns the start of the first window after t
+func (w *deliveryWindow) next(t time.Time) time.Time {
+	local := t.In(w.location)
+	start := w.start
+	if w.start == w.end {
+		start = 0
+	}
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, w.location)
+		if candidate.After(local) && w.weekdays[candidate.Weekday()] {
+			return candidate
+		}
+	}
+	return local.Add(minutesPerDay * time.Minute)
+}
+
+// scheduler holds back deliveries that fall outside the delivery window of their recipient
+type scheduler struct {
+	mu       sync.Mutex
+	windows  map[string]*deliveryWindow // by recipient name
+	deferred map[*time.Timer]delivery
+	stopped  bool       // deliveries deferred after stop are held for the next call to stop
+	held     []delivery // instead of getting a timer
+	deliver  func(d delivery)
+	// counts the deferred deliveries being sent, a timer that fires after stop leaves its delivery to stop
+	pending *sync.WaitGroup
+}
+
+func newScheduler(recipients []config.Recipient, pending *sync.WaitGroup, deliver func(d delivery)) (*scheduler, error) {
+	windows := make(map[string]*deliveryWindow)
+	for _, recipient := range recipients {
+		if !recipient.Schedule.Enabled() {
+			continue
+		}
+		window, err := newDeliveryWindow(recipient.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("recipient '%s': %w", recipient.Name, err)
+		}
+		windows[recipient.Name] = window
+	}
+	return &scheduler{
+		windows:  windows,
+		deferred: make(map[*time.Timer]delivery),
+		deliver:  deliver,
+		pending:  pending,
+	}, nil
+}
+
+// gate returns the delivery to send now, if any. Deferred deliveries are handed to deliver once the window opens.
+func (sc *scheduler) gate(d delivery, now time.Time) (delivery, scheduleAction) {
+	window, ok := sc.windows[d.recipient.Name]
+	if !ok || d.deferred || window.active(now) || d.notification.Severity.AtLeast(window.bypass) {
+		return d, scheduleDeliver
+	}
+
+	switch window.outside {
+	case config.ScheduleOutsideDrop:
+		return d, scheduleDrop
+	case config.ScheduleOutsideSilent:
+		d.notification.Silent = true
+		return d, scheduleDeliver
+	default:
+		d.deferred = true
+		sc.deferUntil(d, window.next(now).Sub(now))
+		return d, scheduleDefer
+	}
+}
+
+func (sc *scheduler) deferUntil(d delivery, delay time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.stopped {
+		sc.held = append(sc.held, d)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		sc.mu.Lock()
+		if _, ok := sc.deferred[timer]; !ok {
+			// taken by stop
+			sc.mu.Unlock()
+			return
+		}
+		delete(sc.deferred, timer)
+		sc.pending.Add(1)
+		sc.mu.Unlock()
+
+		defer sc.pending.Done()
+		sc.deliver(d)
+	})
+	sc.deferred[timer] = d
+}
+
+// stop cancels the deferred deliveries and returns the ones that were still waiting. Deliveries
+// deferred afterwards, e.g. by sends still in progress, are held and returned by the next call.
+func (sc *scheduler) stop() []delivery {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.stopped = true
+	stopped := sc.held
+	sc.held = nil
+	for timer, d := range sc.deferred {
+		timer.Stop()
+		stopped = append(stopped, d)
+	}
+	sc.deferred = make(map[*time.Timer]delivery)
+	return stopped
+}
+
+type scheduleAction int
+
+const (
+	scheduleDeliver scheduleAction = iota
+	scheduleDefer
+	scheduleDrop
+)
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // for machines without a zoneinfo database
+
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// berlin switches to summer time on Sunday, March 31 2024 at 02:00, which becomes 03:00
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func newTestWindow(t *testing.T, cfg config.ScheduleConfig) *deliveryWindow {
+	t.Helper()
+	cfg.Timezone = "Europe/Berlin"
+	w, err := newDeliveryWindow(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestDeliveryWindowActive(t *testing.T) {
+	loc := berlin(t)
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 3, day, hour, minute, 0, 0, loc) }
+	office := config.ScheduleConfig{ActiveHours: "09:00-17:00", Weekdays: []string{"mon", "tue", "wed", "thu", "friday"}}
+	fridayNight := config.ScheduleConfig{ActiveHours: "22:00-06:00", Weekdays: []string{"fri"}}
+	weekend := config.ScheduleConfig{Weekdays: []string{"Sat", "sun"}}
+	daily := config.ScheduleConfig{ActiveHours: "09:00-17:00"}
+
+	tests := []struct {
+		name string
+		cfg  config.ScheduleConfig
+		at   time.Time
+		want bool
+	}{
+		{name: "office hours", cfg: office, at: at(29, 10, 0), want: true},
+		{name: "before office hours", cfg: office, at: at(29, 8, 59)},
+		{name: "end is exclusive", cfg: office, at: at(29, 17, 0)},
+		{name: "office saturday", cfg: office, at: at(30, 10, 0)},
+		{name: "night on its day", cfg: fridayNight, at: at(29, 23, 0), want: true},
+		{name: "night after midnight", cfg: fridayNight, at: at(30, 5, 59), want: true},
+		{name: "night ended", cfg: fridayNight, at: at(30, 6, 0)},
+		{name: "night of another day", cfg: fridayNight, at: at(30, 23, 0)},
+		{name: "early morning of its day belongs to the day before", cfg: fridayNight, at: at(29, 5, 0)},
+		{name: "all day weekend", cfg: weekend, at: at(30, 0, 0), want: true},
+		{name: "all day weekend on friday", cfg: weekend, at: at(29, 23, 59)},
+		{name: "summer time in the window", cfg: daily, at: time.Date(2024, 3, 31, 7, 30, 0, 0, time.UTC), want: true},
+		{name: "winter time before the window", cfg: daily, at: time.Date(2024, 3, 30, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestWindow(t, tt.cfg).active(tt.at); got != tt.want {
+				t.Errorf("active(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryWindowNext(t *testing.T) {
+	loc := berlin(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.ScheduleConfig
+		now  time.Time
+		want time.Time
+	}{
+		{
+			name: "later today",
+			cfg:  config.ScheduleConfig{ActiveHours: "09:00-17:00"},
+			now:  at(3, 29, 7, 0),
+			want: at(3, 29, 9, 0),
+		},
+		{
+			name: "tomorrow across the switch to summer time",
+			cfg:  config.ScheduleConfig{ActiveHours: "09:00-17:00"},
+			now:  at(3, 30, 20, 0),
+			want: at(3, 31, 9, 0), // 12 hours later, not 13
+		},
+		{
+			name: "after the weekend",
+			cfg:  config.ScheduleConfig{ActiveHours: "09:00-17:00", Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}},
+			now:  at(3, 29, 18, 0),
+			want: at(4, 1, 9, 0),
+		},
+		{
+			name: "start of the next window while one is open",
+			cfg:  config.ScheduleConfig{ActiveHours: "22:00-06:00"},
+			now:  at(3, 29, 23, 0),
+			want: at(3, 30, 22, 0),
+		},
+		{
+			name: "midnight of an all day window",
+			cfg:  config.ScheduleConfig{Weekdays: []string{"mon"}},
+			now:  at(3, 30, 12, 0),
+			want: at(4, 1, 0, 0),
+		},
+		{
+			name: "same weekday next week",
+			cfg:  config.ScheduleConfig{ActiveHours: "09:00-10:00", Weekdays: []string{"fri"}},
+			now:  at(3, 29, 9, 30),
+			want: at(4, 5, 9, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestWindow(t, tt.cfg).next(tt.now); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryWindowNextSkipsMissingHour(t *testing.T) {
+	// 02:30 does not exist on March 31 in Berlin
+	w := newTestWindow(t, config.ScheduleConfig{ActiveHours: "02:30-04:00"})
+	now := time.Date(2024, 3, 30, 12, 0, 0, 0, berlin(t))
+
+	next := w.next(now)
+	if !next.After(now) || !w.active(next) {
+		t.Errorf("next(%s) = %s, want a moment in the window", now, next)
+	}
+}
+
+func TestNewDeliveryWindowErrors(t *testing.T) {
+	for _, cfg := range []config.ScheduleConfig{
+		{ActiveHours: "09:00"},
+		{ActiveHours: "9-17"},
+		{ActiveHours: "09:00-24:00"},
+		{Weekdays: []string{"someday"}},
+		{Weekdays: []string{"mon"}, Timezone: "Europe/Atlantis"},
+		{Weekdays: []string{"mon"}, Outside: "queue"},
+		{Weekdays: []string{"mon"}, BypassSeverity: "urgent"},
+	} {
+		if _, err := newDeliveryWindow(cfg); err == nil {
+			t.Errorf("newDeliveryWindow(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestSchedulerGate(t *testing.T) {
+	// every window is closed on friday evening
+	now := time.Date(2024, 3, 29, 20, 0, 0, 0, berlin(t))
+	closed := config.ScheduleConfig{Timezone: "Europe/Berlin", ActiveHours: "09:00-17:00"}
+	schedule := func(outside, bypass string) config.ScheduleConfig {
+		cfg := closed
+		cfg.Outside, cfg.BypassSeverity = outside, bypass
+		return cfg
+	}
+	sc, err := newScheduler([]config.Recipient{
+		{Name: "always"},
+		{Name: "defer", Schedule: closed},
+		{Name: "drop", Schedule: schedule(config.ScheduleOutsideDrop, "")},
+		{Name: "silent", Schedule: schedule(config.ScheduleOutsideSilent, "")},
+		{Name: "bypass", Schedule: schedule(config.ScheduleOutsideDrop, "warning")},
+		{Name: "open", Schedule: config.ScheduleConfig{Timezone: "Europe/Berlin", ActiveHours: "18:00-22:00"}},
+	}, nil, func(delivery) { t.Error("deferred delivery sent before its window") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		recipient  string
+		severity   domain.Severity
+		deferred   bool // held back already
+		wantAction scheduleAction
+		wantSilent bool
+	}{
+		{recipient: "always", severity: domain.SeverityInfo, wantAction: scheduleDeliver},
+		{recipient: "open", severity: domain.SeverityInfo, wantAction: scheduleDeliver},
+		{recipient: "defer", severity: domain.SeverityWarning, wantAction: scheduleDefer},
+		{recipient: "defer", severity: domain.SeverityCritical, wantAction: scheduleDeliver},
+		{recipient: "defer", severity: domain.SeverityInfo, deferred: true, wantAction: scheduleDeliver},
+		{recipient: "drop", severity: domain.SeverityWarning, wantAction: scheduleDrop},
+		{recipient: "silent", severity: domain.SeverityInfo, wantAction: scheduleDeliver, wantSilent: true},
+		{recipient: "bypass", severity: domain.SeverityInfo, wantAction: scheduleDrop},
+		{recipient: "bypass", severity: domain.SeverityWarning, wantAction: scheduleDeliver},
+	}
+	for _, tt := range tests {
+		d := delivery{
+			recipient:    config.Recipient{Name: tt.recipient},
+			notification: domain.Notification{Body: "event", Severity: tt.severity},
+			deferred:     tt.deferred,
+		}
+		got, action := sc.gate(d, now)
+		if action != tt.wantAction || got.notification.Silent != tt.wantSilent {
+			t.Errorf("gate(%s, %s, deferred %v) = action %d silent %v, want %d silent %v",
+				tt.recipient, tt.severity, tt.deferred, action, got.notification.Silent, tt.wantAction, tt.wantSilent)
+		}
+	}
+
+	stopped := sc.stop()
+	if len(stopped) != 1 || stopped[0].recipient.Name != "defer" || !stopped[0].deferred {
+		t.Errorf("stop() = %+v, want the deferred warning", stopped)
+	}
+
+	// deferred after stop, e.g. by a send still in progress
+	sc.gate(delivery{recipient: config.Recipient{Name: "defer"}, notification: domain.Notification{Severity: domain.SeverityInfo}}, now)
+	if held := sc.stop(); len(held) != 1 {
+		t.Errorf("second stop() = %+v, want the delivery deferred after the first", held)
+	}
+}
@@ -2,77 +2,354 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/common"
 	"github.com/shanth1/hookrelay/internal/config"
 	"github.com/shanth1/hookrelay/internal/core/domain"
 	"github.com/shanth1/hookrelay/internal/core/ports"
 )
 
 type Service struct {
-	handlers  map[config.WebhookName]ports.WebhookHandler
-	notifiers map[config.NotifierName]ports.Notifier
-	logger    log.Logger
+	handlers   map[config.WebhookName]ports.WebhookHandler
+	notifiers  map[config.NotifierName]ports.Notifier
+	recipients map[string]config.Recipient // by name
+	digests    *digester
+	templates  *common.TemplateRenderer // of digest headers
+	schedules  *scheduler
+	store      ports.Store
+	logger     log.Logger
+
+	// background is used for deliveries that outlive the webhook request, e.g. digests
+	background context.Context
+	pending    sync.WaitGroup
 }
 
 var _ ports.Service = (*Service)(nil)
 
+// delivery is a notification rendered for a single recipient
+type delivery struct {
+	recipient    config.Recipient
+	notification domain.Notification
+	deferred     bool // already held back by the recipient schedule
+}
+
 func New(
+	cfg *config.Config,
 	handlers map[config.WebhookName]ports.WebhookHandler,
 	notifiers map[config.NotifierName]ports.Notifier,
+	store ports.Store,
 	logger log.Logger,
-) ports.Service {
-	return &Service{
-		logger:    logger,
-		handlers:  handlers,
-		notifiers: notifiers,
+) (*Service, error) {
+	s := &Service{
+		store:      store,
+		logger:     logger,
+		handlers:   handlers,
+		notifiers:  notifiers,
+		recipients: make(map[string]config.Recipient),
+		background: log.NewContext(context.Background(), logger),
+	}
+	for _, recipient := range cfg.Recipients {
+		s.recipients[recipient.Name] = recipient
+	}
+
+	templates, err := newDigestRenderer()
+	if err != nil {
+		return nil, err
+	}
+	s.templates = templates
+
+	digests := make(map[config.WebhookName]config.DigestConfig)
+	for _, webhookCfg := range cfg.Webhooks {
+		digests[webhookCfg.Name] = webhookCfg.Digest
+	}
+	digester, err := newDigester(digests, &s.pending, s.flushDigest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest config: %w", err)
+	}
+	s.digests = digester
+
+	schedules, err := newScheduler(cfg.Recipients, &s.pending, s.deliverDeferred)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule config: %w", err)
+	}
+	s.schedules = schedules
+
+	return s, nil
+}
+
+// Validate checks the digest and schedule settings of the config without creating a service
+func Validate(cfg *config.Config) []error {
+	var errs []error
+	for _, webhookCfg := range cfg.Webhooks {
+		if !webhookCfg.Digest.Enabled() {
+			continue
+		}
+		if _, err := newDigestPolicy(webhookCfg.Digest); err != nil {
+			errs = append(errs, fmt.Errorf("invalid digest config: webhook '%s': %w", webhookCfg.Name, err))
+		}
+	}
+	for _, recipient := range cfg.Recipients {
+		if !recipient.Schedule.Enabled() {
+			continue
+		}
+		if _, err := newDeliveryWindow(recipient.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule config: recipient '%s': %w", recipient.Name, err))
+		}
+	}
+	return errs
+}
+
+// Shutdown flushes pending digests and waits for background deliveries, including the ones queued by
+// notifiers that implement ports.Drainer. Notifications deferred by recipient schedules are saved to
+// the store, RestoreDeferred schedules them again on the next start.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.digests.flushAll()
+
+	deferred := s.schedules.stop()
+	err := s.wait(ctx)
+	deferred = append(deferred, s.schedules.stop()...)
+	if len(deferred) > 0 {
+		if err := s.saveDeferred(context.WithoutCancel(ctx), deferred); err != nil {
+			s.logger.Error().Err(err).Int("count", len(deferred)).Msg("failed to save notifications deferred by recipient schedules")
+		}
+	}
+	return err
+}
+
+// Handover retires s in favour of next, which was created from a reloaded config. Pending digests of
+// webhooks with the same digest config and the notifications deferred by recipient schedules are passed
+// on to next, the other digests are flushed. Then Handover waits for background deliveries like Shutdown.
+// s must not receive webhooks any more.
+func (s *Service) Handover(ctx context.Context, next *Service) error {
+	s.digests.handover(next.digests, next.recipients)
+
+	deferred := s.schedules.stop()
+	err := s.wait(ctx)
+	deferred = append(deferred, s.schedules.stop()...)
+	if len(deferred) > 0 {
+		next.adopt(deferred)
+	}
+	return err
+}
+
+// adopt schedules the deliveries deferred by a previous service for the recipients of the same name
+func (s *Service) adopt(deliveries []delivery) {
+	adopted := make([]delivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		recipient, ok := s.recipients[d.recipient.Name]
+		if !ok {
+			s.logger.Warn().Str("recipient", d.recipient.Name).Msg("discarded deferred notification of a removed recipient")
+			continue
+		}
+		recipient.TemplateVariant = d.recipient.TemplateVariant
+		d.recipient, d.deferred = recipient, false
+		adopted = append(adopted, d)
+	}
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.broadcast(s.background, adopted)
+	}()
+}
+
+func (s *Service) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	// notifiers that queue the notifications have them delivered only now
+	var errs []error
+	for name, notifier := range s.notifiers {
+		if drainer, ok := notifier.(ports.Drainer); ok {
+			if err := drainer.Drain(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("notifier '%s': %w", name, err))
+			}
+		}
 	}
+	return errors.Join(errs...)
 }
 
 func (s *Service) ProcessWebhook(ctx context.Context, webhookName config.WebhookName, req ports.WebhookRequest, recipients []config.Recipient) error {
 	webhookHandler, ok := s.handlers[webhookName]
 	if !ok {
-		return fmt.Errorf("no handler registered for webhook name: %s", webhookHandler)
+		return fmt.Errorf("no handler registered for webhook name: %s", webhookName)
 	}
 
-	notification, err := webhookHandler.Handle(ctx, req)
+	event, err := webhookHandler.Handle(ctx, req)
 	if err != nil {
 		return fmt.Errorf("failed to process request payload: %w", err)
 	}
 
-	if notification == nil {
-		s.logger.Info().Str("name", string(webhookName)).Msg("handler returned no notification, skipping broadcast")
+	if event == nil {
+		s.logger.Info().Str("name", string(webhookName)).Msg("handler returned no event, skipping broadcast")
 		return nil
 	}
 
-	s.broadcast(ctx, recipients, *notification)
+	deliveries, err := s.render(ctx, webhookName, webhookHandler, *event, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	if len(deliveries) == 0 {
+		s.logger.Info().Str("name", string(webhookName)).Str("event", event.Name).Msg("handler returned no notification, skipping broadcast")
+		return nil
+	}
+
+	if s.digests.enabled(webhookName) {
+		for _, d := range deliveries {
+			s.digests.add(webhookName, d)
+		}
+		return nil
+	}
+
+	s.broadcast(ctx, deliveries)
 	return nil
 }
 
-func (s *Service) broadcast(ctx context.Context, recipients []config.Recipient, notification domain.Notification) {
+func (s *Service) flushDigest(buf *digestBuffer) {
+	notification, err := combineNotifications(s.templates, buf)
+	if err != nil {
+		s.logger.Error().Err(err).Str("recipient", buf.recipient.Name).Str("name", string(buf.webhook)).Msg("failed to render digest")
+		return
+	}
+	s.broadcast(s.background, []delivery{{recipient: buf.recipient, notification: notification}})
+}
+
+func (s *Service) deliverDeferred(d delivery) {
+	s.broadcast(s.background, []delivery{d})
+}
+
+// render executes the handler once per group of recipients sharing the same render options
+func (s *Service) render(ctx context.Context, webhookName config.WebhookName, handler ports.WebhookHandler, event domain.Event, recipients []config.Recipient) ([]delivery, error) {
+	groups := make(map[domain.RenderOptions][]config.Recipient)
+	for _, recipient := range recipients {
+		opts := domain.RenderOptions{Variant: recipient.TemplateVariant, Locale: recipient.Locale}
+		groups[opts] = append(groups[opts], recipient)
+	}
+
+	deliveries := make([]delivery, 0, len(recipients))
+	for opts, group := range groups {
+		notification, err := handler.Render(ctx, event, opts)
+		if err != nil {
+			return nil, err
+		}
+		if notification == nil {
+			continue
+		}
+		if notification.Severity == "" {
+			notification.Severity = event.Severity
+		}
+		notification.Webhook = string(webhookName)
+		notification.Event = event.Name
+		notification.EntityKey = event.EntityKey
+		notification.Payload = event.Payload
+		for _, recipient := range group {
+			deliveries = append(deliveries, delivery{recipient: recipient, notification: *notification})
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *Service) broadcast(ctx context.Context, deliveries []delivery) {
 	var wg sync.WaitGroup
 	logger := log.FromContext(ctx)
+	now := time.Now()
 
-	for _, recipient := range recipients {
-		notifier, ok := s.notifiers[recipient.Notifier]
+	var ready []delivery
+	for _, d := range deliveries {
+		notifier, ok := s.notifiers[d.recipient.Notifier]
 		if !ok {
-			logger.Error().Str("recipient", recipient.Name).Str("notifier", string(recipient.Notifier)).Msg("notifier not found")
+			logger.Error().Str("recipient", d.recipient.Name).Str("notifier", string(d.recipient.Notifier)).Msg("notifier not found")
+			continue
+		}
+
+		if filter, ok := notifier.(ports.RecipientFilter); ok && !filter.Accepts(ctx, d.recipient, d.notification) {
+			logger.Info().Str("recipient", d.recipient.Name).Msg("notification filtered out by the recipient settings")
+			continue
+		}
+
+		d, action := s.schedules.gate(d, now)
+		switch action {
+		case scheduleDefer:
+			logger.Info().Str("recipient", d.recipient.Name).Msg("notification deferred until the recipient schedule opens")
+			continue
+		case scheduleDrop:
+			logger.Info().Str("recipient", d.recipient.Name).Msg("notification dropped outside the recipient schedule")
 			continue
 		}
+		ready = append(ready, d)
+	}
 
-		wg.Add(1)
-		go func(rcp config.Recipient, ntf ports.Notifier) {
-			defer wg.Done()
-			if err := ntf.Send(ctx, rcp.Target, notification); err != nil {
-				logger.Error().
-					Str("recipient", rcp.Name).
-					Str("notifier_name", string(rcp.Notifier)).
-					Err(err).
-					Msg("failed to send notification")
+	logFailure := func(recipient config.Recipient, err error) {
+		logger.Error().
+			Str("recipient", recipient.Name).
+			Str("notifier_name", string(recipient.Notifier)).
+			Err(err).
+			Msg("failed to send notification")
+	}
+
+	for _, batch := range batches(ready) {
+		notifier := s.notifiers[batch[0].recipient.Notifier]
+
+		if batchNotifier, ok := notifier.(ports.BatchNotifier); ok && len(batch) > 1 {
+			recipients := make([]config.Recipient, len(batch))
+			for i, d := range batch {
+				recipients[i] = d.recipient
 			}
-		}(recipient, notifier)
+			wg.Add(1)
+			go func(recipients []config.Recipient, notification domain.Notification) {
+				defer wg.Done()
+				failed := batchNotifier.SendBatch(ctx, recipients, notification)
+				for _, recipient := range recipients {
+					if err, ok := failed[recipient.Name]; ok {
+						logFailure(recipient, err)
+					}
+				}
+			}(recipients, batch[0].notification)
+			continue
+		}
+
+		for _, d := range batch {
+			wg.Add(1)
+			go func(d delivery, ntf ports.Notifier) {
+				defer wg.Done()
+				if err := ntf.Send(ctx, d.recipient, d.notification); err != nil {
+					logFailure(d.recipient, err)
+				}
+			}(d, notifier)
+		}
 	}
 	wg.Wait()
 }
+
+// batches groups deliveries of the same notification through the same notifier
+func batches(deliveries []delivery) [][]delivery {
+	var result [][]delivery
+	for _, d := range deliveries {
+		i := slices.IndexFunc(result, func(batch []delivery) bool {
+			return batch[0].recipient.Notifier == d.recipient.Notifier && reflect.DeepEqual(batch[0].notification, d.notification)
+		})
+		if i < 0 {
+			result = append(result, []delivery{d})
+			continue
+		}
+		result[i] = append(result[i], d)
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shanth1/hookrelay/internal/adapters/storage"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+)
+
+// queueingRecorder delivers in the background like the telegram sender, Drain waits for it
+type queueingRecorder struct {
+	recorder
+	queued chan delivery
+	done   chan struct{}
+}
+
+func newQueueingRecorder() *queueingRecorder {
+	r := &queueingRecorder{queued: make(chan delivery, 10), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		for d := range r.queued {
+			time.Sleep(50 * time.Millisecond)
+			r.recorder.Send(context.Background(), d.recipient, d.notification)
+		}
+	}()
+	return r
+}
+
+func (r *queueingRecorder) Send(_ context.Context, recipient config.Recipient, notification domain.Notification) error {
+	r.queued <- delivery{recipient: recipient, notification: notification}
+	return nil
+}
+
+func (r *queueingRecorder) Drain(ctx context.Context) error {
+	close(r.queued)
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestShutdownDrainsNotifiers(t *testing.T) {
+	store, err := storage.New("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	recipient := config.Recipient{Name: "ops", Notifier: "recorder", Target: "chat"}
+	d := delivery{recipient: recipient, notification: domain.Notification{Title: "Push", Body: "main updated"}}
+
+	notifier := newQueueingRecorder()
+	s := newTestService(t, &config.Config{Recipients: []config.Recipient{recipient}}, notifier, store)
+	s.broadcast(ctx, []delivery{d, d})
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := notifier.deliveries(); len(sent) != 2 {
+		t.Errorf("%d deliveries after Shutdown, want 2", len(sent))
+	}
+
+	notifier = newQueueingRecorder()
+	s = newTestService(t, &config.Config{Recipients: []config.Recipient{recipient}}, notifier, store)
+	s.broadcast(ctx, []delivery{d, d})
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want the deadline of ctx", err)
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"embed"
+	"fmt"
+	"text/template"
+
+	"github.com/shanth1/hookrelay/internal/common"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+//go:embed templates/subjects/*.tmpl
+var subjectFiles embed.FS
+
+// newDigestRenderer prepares the header templates of digests, 'digest.<locale>.tmpl'
+func newDigestRenderer() (*common.TemplateRenderer, error) {
+	tmpls, err := template.New("digest").Funcs(common.TemplateFuncs()).ParseFS(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest templates: %w", err)
+	}
+	subjects, err := template.New("digest-subjects").Funcs(common.TemplateFuncs()).ParseFS(subjectFiles, "templates/subjects/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest subject templates: %w", err)
+	}
+	renderer, err := common.NewTemplateRenderer(tmpls, subjects, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare digest templates: %w", err)
+	}
+	return renderer, nil
+}
@@ -75,6 +75,9 @@ func (a *API) webhookHandlerFactory(webhookCfg config.WebhookConfig) http.Handle
 	resolvedRecipients := make([]config.Recipient, 0, len(webhookCfg.Recipients))
 	for _, name := range webhookCfg.Recipients {
 		if r, ok := recipientMap[name]; ok {
+			if r.TemplateVariant == "" {
+				r.TemplateVariant = webhookCfg.TemplateVariant
+			}
 			resolvedRecipients = append(resolvedRecipients, r)
 		}
 	}
@@ -7,7 +7,8 @@ import (
 	"github.com/shanth1/hookrelay/internal/transport/http/middleware"
 )
 
-func NewRouter(api *API, logger log.Logger) http.Handler {
+// NewRouter serves the API and the webhooks, the middlewares run inside recovery and request logging
+func NewRouter(api *API, logger log.Logger, middlewares ...middleware.Middleware) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", api.handleHealthCheck)
@@ -26,7 +27,9 @@ func NewRouter(api *API, logger log.Logger) http.Handler {
 
 	return middleware.Chain(
 		mux,
-		middleware.WithRecovery(logger),
-		middleware.WithLogger(logger),
+		append([]middleware.Middleware{
+			middleware.WithRecovery(logger),
+			middleware.WithLogger(logger),
+		}, middlewares...)...,
 	)
 }
@@ -0,0 +1,74 @@
+package hookrelay
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+
+	// The built-in adapters register their webhook and notifier types when imported
+	_ "github.com/shanth1/hookrelay/internal/adapters/inbound/custom"
+	_ "github.com/shanth1/hookrelay/internal/adapters/inbound/github"
+	_ "github.com/shanth1/hookrelay/internal/adapters/inbound/kanboard"
+	_ "github.com/shanth1/hookrelay/internal/adapters/inbound/plugin"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/amqpbus"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/command"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/email"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/filesink"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/gotify"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/matrix"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/mattermost"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/natsbus"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/ntfy"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/pushover"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/redisbus"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/rocketchat"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/syslog"
+	_ "github.com/shanth1/hookrelay/internal/adapters/outbound/telegram"
+)
+
+// WebhookFactory creates the handlers of a custom webhook type. S is its settings struct,
+// decoded from the 'settings' of the webhook; types without settings use struct{}.
+type WebhookFactory[S any] struct {
+	Validate func(cfg WebhookConfig, settings S) error // optional, must not have side effects
+	New      func(cfg WebhookConfig, settings S, env Env) (WebhookHandler, error)
+}
+
+// NotifierFactory creates the notifiers of a custom type. S is its settings struct,
+// decoded from the 'settings' of the notifier.
+type NotifierFactory[S any] struct {
+	Validate func(settings S) error // optional, must not have side effects such as connecting to a server
+	// ValidateRecipient checks the target and the options of a recipient against valid settings, optional.
+	// Recipients of a type without it must not have options.
+	ValidateRecipient func(settings S, recipient Recipient) error
+	New               func(name NotifierName, settings S, env Env) (Notifier, error)
+}
+
+// RegisterWebhook makes a webhook type available to the config of every server.
+// It is meant to be called in init and panics if the type is registered already.
+func RegisterWebhook[S any](typ WebhookType, f WebhookFactory[S]) {
+	registry.RegisterWebhook(typ, registry.Webhook[S](f))
+}
+
+// RegisterNotifier makes a notifier type available to the config of every server.
+// It is meant to be called in init and panics if the type is registered already.
+func RegisterNotifier[S any](typ NotifierType, f NotifierFactory[S]) {
+	registry.RegisterNotifier(typ, registry.Notifier[S](f))
+}
+
+// NewWebhookHandler creates a handler of a registered type, e.g. to parse GitHub webhooks outside of a server
+func NewWebhookHandler(cfg WebhookConfig, env Env) (WebhookHandler, error) {
+	return registry.NewWebhookHandler(cfg, env)
+}
+
+// NewNotifier creates a notifier of a registered type, e.g. to send to Telegram outside of a server
+func NewNotifier(cfg NotifierConfig, env Env) (Notifier, error) {
+	return registry.NewNotifier(cfg, env)
+}
+
+// WebhookTypes returns the registered webhook types in alphabetical order
+func WebhookTypes() []WebhookType {
+	return registry.WebhookTypes()
+}
+
+// NotifierTypes returns the registered notifier types in alphabetical order
+func NotifierTypes() []NotifierType {
+	return registry.NotifierTypes()
+}
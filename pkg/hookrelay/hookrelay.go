@@ -0,0 +1,53 @@
+// Package hookrelay embeds the webhook relay in another program. It builds the server from a Config,
+// optionally with custom adapters, HTTP middleware, a logger and a storage.
+package hookrelay
+
+import (
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/config"
+	"github.com/shanth1/hookrelay/internal/core/domain"
+	"github.com/shanth1/hookrelay/internal/core/ports"
+	"github.com/shanth1/hookrelay/internal/transport/http/middleware"
+)
+
+// Configuration, as read from the YAML file
+type (
+	Config         = config.Config
+	WebhookConfig  = config.WebhookConfig
+	NotifierConfig = config.NotifierConfig
+	Recipient      = config.Recipient
+
+	WebhookType  = config.WebhookType
+	NotifierType = config.NotifierType
+	WebhookName  = config.WebhookName
+	NotifierName = config.NotifierName
+)
+
+// Adapters and what they exchange with the service
+type (
+	WebhookHandler  = ports.WebhookHandler
+	WebhookRequest  = ports.WebhookRequest
+	Notifier        = ports.Notifier
+	BatchNotifier   = ports.BatchNotifier
+	RecipientFilter = ports.RecipientFilter
+	Drainer         = ports.Drainer
+	Runner          = ports.Runner
+	Store           = ports.Store
+
+	Event         = domain.Event
+	Notification  = domain.Notification
+	RenderOptions = domain.RenderOptions
+	Button        = domain.Button
+	Severity      = domain.Severity
+
+	// Env holds what adapters may need besides their own settings
+	Env = registry.Env
+	// Middleware wraps the HTTP handler of the server
+	Middleware = middleware.Middleware
+)
+
+const (
+	SeverityInfo     = domain.SeverityInfo
+	SeverityWarning  = domain.SeverityWarning
+	SeverityCritical = domain.SeverityCritical
+)
@@ -0,0 +1,50 @@
+package hookrelay
+
+import "github.com/shanth1/gotools/log"
+
+type options struct {
+	logger      log.Logger
+	store       Store
+	middlewares []Middleware
+	handlers    map[WebhookName]WebhookHandler
+	notifiers   map[NotifierName]Notifier
+}
+
+type Option func(*options)
+
+// WithLogger sets the logger of the server and its adapters, log.New() by default
+func WithLogger(logger log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithStore keeps the adapter state in store instead of the file in the 'storage' config
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithMiddleware wraps the HTTP handler, inside the built-in recovery and request logging.
+// The first middleware is the outermost one.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithWebhookHandler handles the webhook with this name in the config with handler, whatever its type
+func WithWebhookHandler(name WebhookName, handler WebhookHandler) Option {
+	return func(o *options) {
+		o.handlers[name] = handler
+	}
+}
+
+// WithNotifier delivers the notifications of the notifier with this name in the config with notifier,
+// whatever its type
+func WithNotifier(name NotifierName, notifier Notifier) Option {
+	return func(o *options) {
+		o.notifiers[name] = notifier
+	}
+}
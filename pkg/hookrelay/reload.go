@@ -0,0 +1,79 @@
+package hookrelay
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+// Loader reads the config again for a reload
+type Loader func() (*Config, error)
+
+// WatchConfig reloads the config with load on SIGHUP and, with 'reload.watch' set, whenever the content
+// of the file at path changes, until ctx is cancelled. A config that fails to load or is rejected by
+// Reload is logged and the current one is kept.
+func (s *Server) WatchConfig(ctx context.Context, path string, load Loader) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var changes <-chan time.Time
+	settings := s.current.Load().cfg.Reload
+	if settings.Watch && path != "" {
+		interval := settings.Interval
+		if interval <= 0 {
+			interval = defaultWatchInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		changes = ticker.C
+	}
+	version, _ := fileVersion(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			s.reload(ctx, load, "signal")
+		case <-changes:
+			// a file being replaced may be missing for a moment, the next check picks it up
+			current, ok := fileVersion(path)
+			if !ok || current == version {
+				continue
+			}
+			version = current
+			s.reload(ctx, load, "file change")
+		}
+	}
+}
+
+func (s *Server) reload(ctx context.Context, load Loader, trigger string) {
+	logger := s.logger.With(log.Str("trigger", trigger))
+	logger.Info().Msg("reloading config")
+
+	cfg, err := load()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load config, keeping the current one")
+		return
+	}
+	if err := s.Reload(ctx, cfg); err != nil {
+		logger.Error().Err(err).Msg("invalid config, keeping the current one")
+	}
+}
+
+// fileVersion identifies the content of a file, editors and config maps may replace it without changing its size or time
+func fileVersion(path string) ([sha256.Size]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(data), true
+}
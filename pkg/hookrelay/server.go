@@ -0,0 +1,371 @@
+package hookrelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shanth1/gotools/log"
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/adapters/storage"
+	"github.com/shanth1/hookrelay/internal/service"
+	httptransport "github.com/shanth1/hookrelay/internal/transport/http"
+)
+
+// handoverTimeout bounds how long Reload waits for the requests and deliveries of the previous config,
+// which blocks other reloads and Shutdown meanwhile
+const handoverTimeout = 30 * time.Second
+
+// retirePollInterval is how often Reload checks whether the requests of the previous config are done
+const retirePollInterval = 10 * time.Millisecond
+
+// Server receives the webhooks of a config and delivers their notifications.
+// The config can be replaced while the server runs, see Reload.
+type Server struct {
+	opts    options
+	logger  log.Logger
+	store   Store
+	handler http.Handler
+
+	current atomic.Pointer[instance]
+
+	mu      sync.Mutex      // serializes Start, Reload and Shutdown
+	ctx     context.Context // of the background work, set by Start
+	stopped bool
+}
+
+// instance is what a config is built into, it is replaced as a whole on reload
+type instance struct {
+	cfg       *Config
+	handlers  map[WebhookName]WebhookHandler
+	notifiers map[NotifierName]Notifier
+	service   *service.Service
+	handler   http.Handler
+
+	// requests hold a read lock while they are served, retiring waits until the write lock is free
+	mu      sync.RWMutex
+	retired atomic.Bool
+
+	cancel  context.CancelFunc // stops the background work
+	runners sync.WaitGroup
+}
+
+// New creates the adapters of the config and the HTTP handler serving its webhooks. It fails on duplicate
+// names and paths and unknown references, Validate checks the config more thoroughly.
+// Nothing runs until Start or Run is called.
+func New(cfg *Config, opts ...Option) (*Server, error) {
+	s := &Server{
+		opts: options{
+			handlers:  make(map[WebhookName]WebhookHandler),
+			notifiers: make(map[NotifierName]Notifier),
+		},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	s.logger = s.opts.logger
+	if s.logger == nil {
+		s.logger = log.New()
+	}
+	s.store = s.opts.store
+	if s.store == nil {
+		store, err := storage.New(cfg.Storage.Path, cfg.Storage.EntityTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		s.store = store
+	}
+
+	inst, err := s.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(inst)
+	s.handler = http.HandlerFunc(s.serveHTTP)
+	return s, nil
+}
+
+func (s *Server) build(cfg *Config) (*instance, error) {
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	env := Env{Config: cfg, Store: s.store}
+
+	handlers, err := s.initInboundHandlers(cfg, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inbound processors: %w", err)
+	}
+	notifiers, err := s.initOutboundAdapters(cfg, env)
+	if err != nil {
+		s.closeAdapters(handlers, nil)
+		return nil, fmt.Errorf("failed to initialize outbound adapters: %w", err)
+	}
+	webhookService, err := service.New(cfg, handlers, notifiers, s.store, s.logger)
+	if err != nil {
+		s.closeAdapters(handlers, notifiers)
+		return nil, fmt.Errorf("failed to initialize service: %w", err)
+	}
+
+	api := httptransport.NewAPI(webhookService, s.logger, cfg)
+	return &instance{
+		cfg:       cfg,
+		handlers:  handlers,
+		notifiers: notifiers,
+		service:   webhookService,
+		handler:   httptransport.NewRouter(api, s.logger, s.opts.middlewares...),
+	}, nil
+}
+
+func (s *Server) initInboundHandlers(cfg *Config, env Env) (map[WebhookName]WebhookHandler, error) {
+	handlers := make(map[WebhookName]WebhookHandler)
+	for _, webhookCfg := range cfg.Webhooks {
+		handler, ok := s.opts.handlers[webhookCfg.Name]
+		if !ok {
+			var err error
+			if handler, err = registry.NewWebhookHandler(webhookCfg, env); err != nil {
+				s.closeAdapters(handlers, nil)
+				return nil, err
+			}
+		}
+		handlers[webhookCfg.Name] = handler
+		s.logger.Info().Str("name", string(webhookCfg.Name)).Str("type", string(webhookCfg.Type)).Msg("registered webhook handler")
+	}
+	return handlers, nil
+}
+
+func (s *Server) initOutboundAdapters(cfg *Config, env Env) (map[NotifierName]Notifier, error) {
+	notifiers := make(map[NotifierName]Notifier)
+	for _, notifierCfg := range cfg.Notifiers {
+		notifier, ok := s.opts.notifiers[notifierCfg.Name]
+		if !ok {
+			var err error
+			if notifier, err = registry.NewNotifier(notifierCfg, env); err != nil {
+				s.closeAdapters(nil, notifiers)
+				return nil, err
+			}
+		}
+		notifiers[notifierCfg.Name] = notifier
+		s.logger.Info().Str("name", string(notifierCfg.Name)).Str("type", string(notifierCfg.Type)).Msg("registered notifier")
+	}
+	return notifiers, nil
+}
+
+// Handler serves the webhook paths of the config and the API endpoints, for mounting in another HTTP server.
+// It keeps serving the current config after a reload.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	for {
+		inst := s.current.Load()
+		inst.mu.RLock()
+		if inst.retired.Load() {
+			// replaced while this request was waiting for the lock
+			inst.mu.RUnlock()
+			continue
+		}
+		inst.handler.ServeHTTP(w, r)
+		inst.mu.RUnlock()
+		return
+	}
+}
+
+// Start launches the background work of the adapters, such as bot command polling and plugin processes,
+// and schedules the notifications deferred by recipient schedules when the server last shut down.
+// It stops when ctx is cancelled.
+func (s *Server) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx = log.NewContext(ctx, s.logger)
+	inst := s.current.Load()
+	s.start(inst)
+	if err := inst.service.RestoreDeferred(s.ctx); err != nil {
+		s.logger.Error().Err(err).Msg("failed to restore deferred notifications")
+	}
+}
+
+func (s *Server) start(inst *instance) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	inst.cancel = cancel
+	for name, handler := range inst.handlers {
+		if runner, ok := handler.(Runner); ok {
+			inst.run(ctx, runner, s.logger.With(log.Str("webhook", string(name))))
+		}
+	}
+	for name, notifier := range inst.notifiers {
+		if runner, ok := notifier.(Runner); ok {
+			inst.run(ctx, runner, s.logger.With(log.Str("name", string(name))))
+		}
+	}
+}
+
+func (inst *instance) run(ctx context.Context, runner Runner, logger log.Logger) {
+	inst.runners.Add(1)
+	go func() {
+		defer inst.runners.Done()
+		if err := runner.Run(ctx); err != nil {
+			logger.Error().Err(err).Msg("adapter background task failed")
+		}
+	}()
+}
+
+// retire turns new requests away from the instance and waits for the ones in progress until ctx is done.
+// A request holds the read lock while it is served, so they are done once the write lock is free.
+// The lock is only tried, so a slow request cannot hold Reload past ctx.
+func (inst *instance) retire(ctx context.Context) error {
+	inst.retired.Store(true)
+
+	ticker := time.NewTicker(retirePollInterval)
+	defer ticker.Stop()
+	for !inst.mu.TryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	inst.mu.Unlock()
+	return nil
+}
+
+// stop ends the background work of the adapters and waits for it
+func (inst *instance) stop() {
+	if inst.cancel != nil {
+		inst.cancel()
+	}
+	inst.runners.Wait()
+}
+
+// Reload replaces the config of the server. The new adapters are created first: if that fails,
+// the current config stays in place and the error is returned. Otherwise new requests go to the new
+// config at once, while the requests in progress and their deliveries finish with the old adapters,
+// as long as ctx allows and at most for handoverTimeout. Digests of webhooks with an unchanged digest config and notifications
+// deferred by recipient schedules carry over, other digests are flushed early. The listen address and the storage are not reloaded.
+func (s *Server) Reload(ctx context.Context, cfg *Config) error {
+	old, err := s.swap(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	// closing may take a while, other reloads and Shutdown need not wait for it
+	s.closeAdapters(old.handlers, old.notifiers)
+	s.logger.Info().Int("webhooks", len(cfg.Webhooks)).Int("notifiers", len(cfg.Notifiers)).Msg("config reloaded")
+	return nil
+}
+
+// swap puts in the instance built from cfg and hands the work of the previous one over to it.
+// It returns the previous instance, whose adapters are left to close.
+func (s *Server) swap(ctx context.Context, cfg *Config) (*instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, errors.New("server is shut down")
+	}
+	inst, err := s.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	old := s.current.Swap(inst)
+	if cfg.Addr != old.cfg.Addr {
+		s.logger.Warn().Str("addr", old.cfg.Addr).Msg("listen address changes need a restart, keeping the current one")
+	}
+	if cfg.Storage.Path != old.cfg.Storage.Path && s.opts.store == nil {
+		s.logger.Warn().Str("path", old.cfg.Storage.Path).Msg("storage changes need a restart, keeping the current one")
+	}
+
+	handoverCtx, cancel := context.WithTimeout(ctx, handoverTimeout)
+	defer cancel()
+	if err := old.retire(handoverCtx); err != nil {
+		s.logger.Warn().Err(err).Msg("requests of the previous config are still in progress")
+	}
+
+	// a bot must not poll for commands twice, so the old background work ends first
+	if s.ctx != nil {
+		old.stop()
+		s.start(inst)
+	}
+
+	if err := old.service.Handover(handoverCtx, inst.service); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to finish deliveries of the previous config")
+	}
+	return old, nil
+}
+
+// Shutdown flushes pending digests and waits for the deliveries in progress, as long as ctx allows,
+// then stops the background work and closes the adapters. The server must not receive webhooks any more.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	inst := s.current.Load()
+	err := inst.service.Shutdown(ctx)
+	inst.stop()
+	s.closeAdapters(inst.handlers, inst.notifiers)
+	// the store passed as an option belongs to the caller
+	if closer, ok := s.store.(io.Closer); ok && s.opts.store == nil {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Error().Err(closeErr).Msg("failed to save storage")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to deliver pending notifications: %w", err)
+	}
+	return nil
+}
+
+// closeAdapters releases the connections and files of adapters that are no longer used.
+// The adapters passed as options belong to the caller and are kept open.
+func (s *Server) closeAdapters(handlers map[WebhookName]WebhookHandler, notifiers map[NotifierName]Notifier) {
+	closeAdapter := func(name string, adapter interface{}) {
+		if closer, ok := adapter.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				s.logger.Warn().Str("name", name).Err(err).Msg("failed to close adapter")
+			}
+		}
+	}
+	for name, handler := range handlers {
+		if _, ok := s.opts.handlers[name]; !ok {
+			closeAdapter(string(name), handler)
+		}
+	}
+	for name, notifier := range notifiers {
+		if _, ok := s.opts.notifiers[name]; !ok {
+			closeAdapter(string(name), notifier)
+		}
+	}
+}
+
+// Run starts the server and serves HTTP on the configured address until ctx is cancelled,
+// then shuts down within shutdownCtx
+func (s *Server) Run(ctx, shutdownCtx context.Context) error {
+	s.Start(ctx)
+
+	addr := s.current.Load().cfg.Addr
+	server := httptransport.NewServer(addr, s.handler)
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info().Msgf("starting HTTP server on %s", addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("http server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info().Msg("shutting down HTTP server...")
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		s.logger.Error().Err(err).Msg("http server graceful shutdown failed")
+	}
+	return s.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,71 @@
+package hookrelay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds the requests until release is closed
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, req WebhookRequest) (*Event, error) {
+	h.started <- struct{}{}
+	<-h.release
+	return nil, nil
+}
+
+func (h *blockingHandler) Render(ctx context.Context, event Event, opts RenderOptions) (*Notification, error) {
+	return nil, nil
+}
+
+func TestReloadDoesNotWaitForSlowRequests(t *testing.T) {
+	handler := &blockingHandler{started: make(chan struct{}, 1), release: make(chan struct{})}
+	cfg := &Config{Webhooks: []WebhookConfig{{Name: "slow", Path: "/slow", Type: "custom"}}}
+	server, err := New(cfg, WithWebhookHandler("slow", handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	defer close(handler.release)
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Post(ts.URL+"/slow", "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-handler.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := server.Reload(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Reload() took %s with a request in progress, want it to stop with ctx", elapsed)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("request finished before it was released: %v", err)
+	default:
+	}
+	handler.release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Errorf("request in progress during the reload failed: %v", err)
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
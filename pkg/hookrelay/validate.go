@@ -0,0 +1,76 @@
+package hookrelay
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/shanth1/hookrelay/internal/adapters/inbound/custom"
+	"github.com/shanth1/hookrelay/internal/adapters/inbound/github"
+	"github.com/shanth1/hookrelay/internal/adapters/inbound/kanboard"
+	"github.com/shanth1/hookrelay/internal/adapters/registry"
+	"github.com/shanth1/hookrelay/internal/common"
+	"github.com/shanth1/hookrelay/internal/service"
+)
+
+// builtinTemplateTypes are the webhook types rendered by the built-in templates, which only exist
+// for the supported locales. Plugins and custom types may render any locale.
+var builtinTemplateTypes = []WebhookType{custom.Type, github.Type, kanboard.Type}
+
+// Validate checks the config without creating adapters or connecting anywhere and returns every problem found:
+// duplicate names and paths, unknown recipients and notifiers, webhook and notifier settings, including keys
+// their adapter does not know, recipient targets, options and locales, templates, digests and schedules.
+// Custom types must be registered before.
+func Validate(cfg *Config) []error {
+	errs := cfg.Validate()
+	for _, webhookCfg := range cfg.Webhooks {
+		if err := registry.ValidateWebhook(webhookCfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	types := registry.NotifierTypes()
+	notifiers := make(map[NotifierName]NotifierConfig)
+	for _, notifierCfg := range cfg.Notifiers {
+		if err := registry.ValidateNotifier(notifierCfg); err != nil {
+			errs = append(errs, err)
+		}
+		if slices.Contains(types, notifierCfg.Type) {
+			notifiers[notifierCfg.Name] = notifierCfg
+		}
+	}
+	for _, recipient := range cfg.Recipients {
+		// recipients of unknown notifiers are reported above
+		if notifierCfg, ok := notifiers[recipient.Notifier]; ok {
+			if err := registry.ValidateRecipient(notifierCfg, recipient); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := validateLocale(cfg, recipient); err != nil {
+			errs = append(errs, fmt.Errorf("recipient '%s': %w", recipient.Name, err))
+		}
+	}
+	return append(errs, service.Validate(cfg)...)
+}
+
+// validateLocale checks the locale of a recipient. A locale without built-in templates is an error
+// unless the recipient gets webhooks that render it themselves.
+func validateLocale(cfg *Config, recipient Recipient) error {
+	if recipient.Locale == "" {
+		return nil
+	}
+	if err := common.ValidateLocale(recipient.Locale); err != nil {
+		return err
+	}
+	if common.IsSupportedLocale(recipient.Locale) {
+		return nil
+	}
+	for _, webhookCfg := range cfg.Webhooks {
+		if slices.Contains(webhookCfg.Recipients, recipient.Name) && !slices.Contains(builtinTemplateTypes, webhookCfg.Type) {
+			return nil
+		}
+	}
+	locales := common.SupportedLocales()
+	slices.Sort(locales)
+	return fmt.Errorf("unsupported locale '%s', the built-in templates are available in %s", recipient.Locale, strings.Join(locales, ", "))
+}
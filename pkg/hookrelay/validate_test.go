@@ -0,0 +1,107 @@
+package hookrelay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateRecipients(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient Recipient
+		webhook   WebhookType // routed to the recipient, 'github' by default
+		wantErr   string      // empty if valid
+	}{
+		{
+			name:      "telegram chat",
+			recipient: Recipient{Notifier: "telegram", Target: "-1001234567890", Options: map[string]interface{}{"message_thread_id": 7}},
+		},
+		{
+			name:      "telegram channel",
+			recipient: Recipient{Notifier: "telegram", Target: "@release_notes"},
+		},
+		{
+			name:      "telegram chat name",
+			recipient: Recipient{Notifier: "telegram", Target: "dev team"},
+			wantErr:   "invalid chat id 'dev team'",
+		},
+		{
+			name:      "telegram option typo",
+			recipient: Recipient{Notifier: "telegram", Target: "42", Options: map[string]interface{}{"disable_notifications": true}},
+			wantErr:   "unknown keys: disable_notifications",
+		},
+		{
+			name:      "telegram negative topic",
+			recipient: Recipient{Notifier: "telegram", Target: "42", Options: map[string]interface{}{"message_thread_id": -1}},
+			wantErr:   "negative 'message_thread_id'",
+		},
+		{
+			name:      "email display name",
+			recipient: Recipient{Notifier: "email", Target: "Ops <ops@example.com>"},
+			wantErr:   "expected a bare address such as 'ops@example.com'",
+		},
+		{
+			name:      "mattermost channel for the REST API",
+			recipient: Recipient{Notifier: "mattermost", Target: "channel-id"},
+		},
+		{
+			name:      "mattermost without a channel for the REST API",
+			recipient: Recipient{Notifier: "mattermost"},
+			wantErr:   "invalid mattermost recipient 'recipient': empty 'target' value",
+		},
+		{
+			name:      "options of a type without any",
+			recipient: Recipient{Notifier: "ntfy", Target: "alerts", Options: map[string]interface{}{"priority": 5}},
+			wantErr:   "ntfy recipients have none",
+		},
+		{
+			name:      "regional locale",
+			recipient: Recipient{Notifier: "ntfy", Target: "alerts", Locale: "ru-RU"},
+		},
+		{
+			name:      "malformed locale",
+			recipient: Recipient{Notifier: "ntfy", Target: "alerts", Locale: "russian"},
+			wantErr:   "invalid locale 'russian'",
+		},
+		{
+			name:      "locale without built-in templates",
+			recipient: Recipient{Notifier: "ntfy", Target: "alerts", Locale: "de"},
+			wantErr:   "unsupported locale 'de'",
+		},
+		{
+			name:      "locale rendered by a plugin",
+			recipient: Recipient{Notifier: "ntfy", Target: "alerts", Locale: "de"},
+			webhook:   "plugin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := WebhookConfig{Name: "source", Path: "/webhook", Type: "github", Secret: "secret", Recipients: []string{"recipient"}}
+			if tt.webhook != "" {
+				webhook.Type = tt.webhook
+				webhook.Settings = map[string]interface{}{"command": "hookrelay-plugin"}
+			}
+			recipient := tt.recipient
+			recipient.Name = "recipient"
+			cfg := &Config{
+				Webhooks: []WebhookConfig{webhook},
+				Notifiers: []NotifierConfig{
+					{Name: "telegram", Type: "telegram", Settings: map[string]interface{}{"token": "token"}},
+					{Name: "email", Type: "email", Settings: map[string]interface{}{"host": "localhost", "from": "relay@example.com"}},
+					{Name: "ntfy", Type: "ntfy"},
+					{Name: "mattermost", Type: "mattermost", Settings: map[string]interface{}{"url": "https://mattermost.example.com", "token": "token"}},
+				},
+				Recipients: []Recipient{recipient},
+			}
+
+			err := errors.Join(Validate(cfg)...)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no errors", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}